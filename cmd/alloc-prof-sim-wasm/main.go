@@ -0,0 +1,59 @@
+//go:build js && wasm
+
+// Command alloc-prof-sim-wasm exposes the simulation engine to JavaScript for
+// use in interactive web pages. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o alloc-prof-sim.wasm ./cmd/alloc-prof-sim-wasm
+//
+// and load it with the wasm_exec.js support file of the Go distribution. It
+// defines a global allocProfSim object with two functions returning JSON:
+//
+//	allocProfSim.list()
+//	allocProfSim.run('{"profilers": ["go"], "workloads": ["interleave"], "rates": [4096], "exp": [5]}')
+//
+// The run config is an engine.Config and the result a list of results.Result.
+// Errors are returned as {"error": "..."}.
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/felixge/alloc-prof-sim/engine"
+)
+
+func main() {
+	js.Global().Set("allocProfSim", js.ValueOf(map[string]interface{}{
+		"list": js.FuncOf(func(this js.Value, args []js.Value) interface{} { return list() }),
+		"run": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			if len(args) != 1 {
+				return errorJSON("want a single JSON config argument")
+			}
+			return run(args[0].String())
+		}),
+	}))
+	select {}
+}
+
+func run(configJSON string) string {
+	var c engine.Config
+	if err := json.Unmarshal([]byte(configJSON), &c); err != nil {
+		return errorJSON(err.Error())
+	}
+	res, err := c.Runner().Run()
+	if err != nil {
+		return errorJSON(err.Error())
+	}
+	data, _ := json.Marshal(res.List)
+	return string(data)
+}
+
+func list() string {
+	data, _ := json.Marshal(engine.List())
+	return string(data)
+}
+
+func errorJSON(msg string) string {
+	data, _ := json.Marshal(map[string]string{"error": msg})
+	return string(data)
+}
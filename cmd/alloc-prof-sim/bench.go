@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// RunBench writes how fast each profiler simulates each workload to w. The
+// profiler engine.Baseline is the workload without any profiler.
+func (c *Cmd) RunBench(w io.Writer) error {
+	runner, err := c.runner()
+	if err != nil {
+		return err
+	}
+	list, err := runner.Bench()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "workload\tprofiler\trate\tops\tallocs\tduration\tops/s\tallocs/s\tns/alloc\t\n")
+	for _, b := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%.3g\t%.3g\t%.2f\t\n",
+			b.Workload, b.Profiler, b.Rate, b.Ops, b.Allocs, b.Duration.Round(time.Microsecond),
+			b.OpsPerSec(), b.AllocsPerSec(), float64(b.Duration.Nanoseconds())/float64(b.Allocs))
+	}
+	return tw.Flush()
+}
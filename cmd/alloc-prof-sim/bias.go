@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"text/tabwriter"
+
+	"github.com/felixge/alloc-prof-sim/engine"
+	"github.com/felixge/alloc-prof-sim/profiler"
+	"github.com/felixge/alloc-prof-sim/results"
+	"github.com/felixge/alloc-prof-sim/stats"
+	"github.com/felixge/alloc-prof-sim/workload"
+)
+
+// biasSamples is the expected number of samples per trial of a bias cell.
+const biasSamples = 100
+
+// Bias checks that the selected profilers estimate the true bytes of single
+// size allocations without bias for iterations random combinations of size and
+// rate. A profiler is flagged as biased for a combination if the confidence
+// interval of its mean relative error across trials lies entirely beyond
+// -assert-max-error, or 1% if unset. The per profiler summary and all flagged
+// combinations are written to w.
+func (c *Cmd) Bias(w io.Writer, iterations int) error {
+	trials := c.Trials
+	if trials < 2 {
+		trials = 30
+	}
+	tolerance := c.AssertMaxError
+	if tolerance == 0 {
+		tolerance = 1
+	}
+	var profilers []string
+	for _, name := range c.Profilers {
+		if name != engine.Reference {
+			profilers = append(profilers, name)
+		}
+	}
+
+	type cell struct {
+		size, rate int
+		errors     map[string][]float64
+	}
+	var cells []cell
+	for i := 0; i < iterations; i++ {
+		seed := engine.DeriveSeed(c.Seed, fmt.Sprintf("bias/%d", i))
+		rnd := rand.New(rand.NewSource(seed))
+		// Rates between 512 B and 1 MiB and sizes between 1 B and 4 times
+		// the rate, both log-uniformly distributed.
+		rate := int(math.Exp(math.Log(512) + rnd.Float64()*math.Log(2048)))
+		size := int(math.Exp(rnd.Float64() * math.Log(4*float64(rate))))
+		n := int(math.Min(math.Ceil(biasSamples*float64(rate)/float64(size)), 1e6))
+
+		runner, err := c.runner()
+		if err != nil {
+			return err
+		}
+		runner.Workloads = []string{"stdin"}
+		runner.Profilers = profilers
+		runner.Rates = []int{rate}
+		runner.Formulas = runner.Formulas[:1]
+		runner.Stream = biasStream(size, n)
+		runner.Stream.Digest = fmt.Sprintf("bias/%d/%d", size, n)
+		runner.Seed, runner.Trials, runner.TrialSeeds = seed, trials, nil
+		res, err := runner.Run()
+		if err != nil {
+			return err
+		}
+
+		cl := cell{size: size, rate: rate, errors: map[string][]float64{}}
+		for _, r := range res.List {
+			if isReference(r.Profiler) {
+				continue
+			}
+			want := res.Index[results.Key{Workload: r.Workload, Profiler: engine.Reference, Rate: r.Rate, Ops: r.Ops, Trial: r.Trial, Seed: r.Seed, Formula: r.Formula}]
+			cl.errors[r.Profiler] = append(cl.errors[r.Profiler], stats.RelError(float64(r.Profile["alloc"].Bytes), float64(want["alloc"].Bytes)))
+		}
+		cells = append(cells, cl)
+	}
+
+	level := 1 - fuzzAlpha/float64(len(cells)*len(profilers))
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "profiler\tsize\trate\tmean error\tinterval\t\n")
+	var (
+		biased  = map[string]int{}
+		flagged int
+	)
+	for _, cl := range cells {
+		for _, name := range profilers {
+			mean, lo, hi := stats.MeanCI(cl.errors[name], level)
+			if math.IsNaN(lo) || (lo <= tolerance && hi >= -tolerance) {
+				continue
+			}
+			biased[name]++
+			flagged++
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f%%\t[%.2f%%, %.2f%%]\t\n", name, cl.size, cl.rate, mean, lo, hi)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, name := range profilers {
+		fmt.Fprintf(w, "%s: %d of %d combinations biased beyond %.2f%%\n", name, biased[name], len(cells), tolerance)
+	}
+	if flagged > 0 {
+		return fmt.Errorf("%d combinations biased", flagged)
+	}
+	return nil
+}
+
+// biasStream returns a stream of n allocations of the given size, preceded by
+// a warmup allocation as in profiler.FuzzStream.
+func biasStream(size, n int) *workload.AllocStream {
+	stream := &workload.AllocStream{Events: []workload.AllocEvent{{Size: 1, Stack: profiler.FuzzWarmup.Stack()}}}
+	for i := 0; i < n; i++ {
+		stream.Events = append(stream.Events, workload.AllocEvent{Size: size, Stack: profiler.StackTrace("alloc")})
+	}
+	return stream
+}
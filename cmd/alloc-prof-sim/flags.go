@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+// IntList is a flag.Value holding a comma separated list of integers. Each
+// element may use a k, m or g suffix for multiples of 1024, e.g. 64k, and may
+// also be an inclusive range such as 5-9 or, with a step, 4k-8k:1k. Ranges
+// with suffixes need a step, as counting 4k-5k by ones is rarely intended.
+type IntList []int
+
+func (l *IntList) String() string {
+	var s []string
+	for _, v := range *l {
+		s = append(s, strconv.Itoa(v))
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *IntList) Set(s string) error {
+	var list IntList
+	if s == "" {
+		*l = list
+		return nil
+	}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		lo, hi, isRange := strings.Cut(f, "-")
+		from, err := parseSize(lo)
+		if err != nil {
+			return err
+		}
+		to, step := from, 1
+		if isRange {
+			hi, stepText, hasStep := strings.Cut(hi, ":")
+			if to, err = parseSize(hi); err != nil {
+				return err
+			} else if to < from {
+				return fmt.Errorf("bad range: %s", f)
+			}
+			if hasStep {
+				if step, err = parseSize(stepText); err != nil {
+					return err
+				} else if step <= 0 {
+					return fmt.Errorf("bad step: %s", f)
+				}
+			} else if hasSuffix(lo) || hasSuffix(hi) {
+				return fmt.Errorf("bad range: %s: ranges with a k, m or g suffix need a step, e.g. %s:1k", f, f)
+			}
+		}
+		for v := from; v <= to; v += step {
+			list = append(list, v)
+		}
+	}
+	*l = list
+	return nil
+}
+
+// parseSize parses an integer with an optional k, m or g suffix denoting a
+// multiple of 1024.
+func parseSize(s string) (int, error) {
+	mult := 1
+	for i, suffix := range []string{"k", "m", "g"} {
+		if strings.HasSuffix(strings.ToLower(s), suffix) {
+			mult = 1 << (10 * (i + 1))
+			s = s[:len(s)-1]
+			break
+		}
+	}
+	v, err := strconv.Atoi(s)
+	return v * mult, err
+}
+
+// hasSuffix reports whether s ends with one of the suffixes of parseSize.
+func hasSuffix(s string) bool {
+	s = strings.ToLower(s)
+	return strings.HasSuffix(s, "k") || strings.HasSuffix(s, "m") || strings.HasSuffix(s, "g")
+}
+
+// StringList is a flag.Value holding a comma separated list of strings.
+type StringList []string
+
+func (l *StringList) String() string { return strings.Join(*l, ",") }
+
+func (l *StringList) Set(s string) error {
+	var list StringList
+	if s == "" {
+		*l = list
+		return nil
+	}
+	for _, f := range strings.Split(s, ",") {
+		list = append(list, strings.TrimSpace(f))
+	}
+	*l = list
+	return nil
+}
+
+// Int64List is a flag.Value holding a comma separated list of int64 values.
+type Int64List []int64
+
+func (l *Int64List) String() string {
+	var s []string
+	for _, v := range *l {
+		s = append(s, strconv.FormatInt(v, 10))
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *Int64List) Set(s string) error {
+	var list Int64List
+	if s == "" {
+		*l = list
+		return nil
+	}
+	for _, f := range strings.Split(s, ",") {
+		v, err := strconv.ParseInt(strings.TrimSpace(f), 10, 64)
+		if err != nil {
+			return err
+		}
+		list = append(list, v)
+	}
+	*l = list
+	return nil
+}
+
+// FloatList is a flag.Value holding a comma separated list of floats.
+type FloatList []float64
+
+func (l *FloatList) String() string {
+	var s []string
+	for _, v := range *l {
+		s = append(s, strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *FloatList) Set(s string) error {
+	var list FloatList
+	if s == "" {
+		*l = list
+		return nil
+	}
+	for _, f := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return err
+		}
+		list = append(list, v)
+	}
+	*l = list
+	return nil
+}
+
+// ExpOverrides is a flag.Value holding per-workload exponents. Each Set call
+// adds a PATTERN=EXP entry where PATTERN is a path.Match glob that is matched
+// against workload names and EXP is parsed like an IntList.
+type ExpOverrides []ExpOverride
+
+type ExpOverride struct {
+	Pattern string
+	Exp     IntList
+}
+
+func (o *ExpOverrides) String() string {
+	var s []string
+	for _, v := range *o {
+		s = append(s, v.Pattern+"="+v.Exp.String())
+	}
+	return strings.Join(s, " ")
+}
+
+func (o *ExpOverrides) Set(s string) error {
+	pattern, exp, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("bad workload exp: %q: want PATTERN=EXP", s)
+	} else if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+	v := ExpOverride{Pattern: pattern}
+	if err := v.Exp.Set(exp); err != nil {
+		return err
+	}
+	*o = append(*o, v)
+	return nil
+}
+
+// Exp returns the exponents for the given workload. The last matching
+// override wins, and false is returned if none match.
+func (o ExpOverrides) Exp(workload string) ([]int, bool) {
+	for i := len(o) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(o[i].Pattern, workload); ok {
+			return o[i].Exp, true
+		}
+	}
+	return nil, false
+}
+
+// ScaleFormulaList is a flag.Value holding a comma separated list of scale
+// formulas.
+type ScaleFormulaList []profiler.ScaleFormula
+
+func (l *ScaleFormulaList) String() string {
+	var s []string
+	for _, v := range *l {
+		s = append(s, string(v))
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *ScaleFormulaList) Set(s string) error {
+	var list ScaleFormulaList
+	for _, f := range strings.Split(s, ",") {
+		v, err := profiler.ParseScaleFormula(strings.TrimSpace(f))
+		if err != nil {
+			return err
+		}
+		list = append(list, v)
+	}
+	*l = list
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIntListSet(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want IntList
+		err  bool
+	}{
+		{in: "", want: nil},
+		{in: "8", want: IntList{8}},
+		{in: "5-9", want: IntList{5, 6, 7, 8, 9}},
+		{in: "64k, 1m", want: IntList{64 << 10, 1 << 20}},
+		{in: "0-10:5", want: IntList{0, 5, 10}},
+		{in: "1-10:4", want: IntList{1, 5, 9}},
+		{in: "4k-8k:2k", want: IntList{4 << 10, 6 << 10, 8 << 10}},
+		{in: "4k-5k", err: true},
+		{in: "1000-2k", err: true},
+		{in: "1-5:0", err: true},
+		{in: "9-5", err: true},
+		{in: "x", err: true},
+	} {
+		var got IntList
+		err := got.Set(tt.in)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Set(%q) = %v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Set(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
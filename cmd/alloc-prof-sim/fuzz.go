@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+
+	"github.com/felixge/alloc-prof-sim/engine"
+	"github.com/felixge/alloc-prof-sim/profiler"
+	"github.com/felixge/alloc-prof-sim/results"
+	"github.com/felixge/alloc-prof-sim/stats"
+	"github.com/felixge/alloc-prof-sim/workload"
+)
+
+// fuzzAlpha is the probability of at least one false positive across all
+// checks of a fuzz run.
+const fuzzAlpha = 0.001
+
+// Fuzz generates iterations random allocation streams and checks that for each
+// stack, the true objects and bytes lie within the confidence interval of the
+// mean estimate across trials for every selected profiler. The confidence
+// level is Bonferroni corrected for the number of checks, so failures indicate
+// a biased estimator rather than bad luck. Checks of stacks that weren't
+// sampled in any trial are inconclusive. Failures are reported to w.
+func (c *Cmd) Fuzz(w io.Writer, iterations int) error {
+	if len(c.Rate) == 0 {
+		return errors.New("no rates to fuzz, see -rate")
+	}
+	trials := c.Trials
+	if trials < 2 {
+		trials = 30
+	}
+	var profilers []string
+	for _, name := range c.Profilers {
+		if name != engine.Reference {
+			profilers = append(profilers, name)
+		}
+	}
+
+	type check struct {
+		iteration int
+		key       results.Key
+		stack     profiler.StackTrace
+		objects   []float64
+		bytes     []float64
+		want      profiler.Alloc
+	}
+	var checks []*check
+	for i := 0; i < iterations; i++ {
+		seed := engine.DeriveSeed(c.Seed, fmt.Sprintf("fuzz/%d", i))
+		rate := c.Rate[i%len(c.Rate)]
+		runner, err := c.runner()
+		if err != nil {
+			return err
+		}
+		runner.Workloads = []string{"stdin"}
+		runner.Profilers = profilers
+		runner.Rates = []int{rate}
+		runner.Stream = fuzzStream(rand.New(rand.NewSource(seed)), rate)
+		runner.Stream.Digest = "fuzz/" + strconv.FormatInt(seed, 10)
+		runner.Seed, runner.Trials, runner.TrialSeeds = seed, trials, nil
+		res, err := runner.Run()
+		if err != nil {
+			return err
+		}
+
+		cells := map[results.Key]map[profiler.StackTrace]*check{}
+		for _, r := range res.List {
+			if isReference(r.Profiler) {
+				continue
+			}
+			want := res.Index[results.Key{Workload: r.Workload, Profiler: engine.Reference, Rate: r.Rate, Ops: r.Ops, Trial: r.Trial, Seed: r.Seed, Formula: r.Formula}]
+			cell := r.Key
+			cell.Trial, cell.Seed = 0, 0
+			if cells[cell] == nil {
+				cells[cell] = map[profiler.StackTrace]*check{}
+			}
+			for _, st := range res.UniqueStacks(r.Workload) {
+				if st == profiler.FuzzWarmup.Stack() {
+					continue
+				}
+				ch := cells[cell][st]
+				if ch == nil {
+					ch = &check{iteration: i, key: cell, stack: st, want: want[st]}
+					cells[cell][st] = ch
+					checks = append(checks, ch)
+				}
+				ch.objects = append(ch.objects, float64(r.Profile[st].Objects))
+				ch.bytes = append(ch.bytes, float64(r.Profile[st].Bytes))
+			}
+		}
+	}
+
+	// Each check compares objects and bytes.
+	level := 1 - fuzzAlpha/float64(2*len(checks))
+	var failures, inconclusive int
+	for _, ch := range checks {
+		for _, m := range []struct {
+			name string
+			got  []float64
+			want float64
+		}{
+			{"objects", ch.objects, float64(ch.want.Objects)},
+			{"bytes", ch.bytes, float64(ch.want.Bytes)},
+		} {
+			mean, lo, hi, result := stats.CheckMean(m.got, m.want, level)
+			if result == stats.CheckPassed {
+				continue
+			} else if result == stats.CheckInconclusive {
+				inconclusive++
+				continue
+			}
+			failures++
+			fmt.Fprintf(w, "%s iteration=%d rate=%d formula=%s stack=%s: %s mean %.0f want %.0f (%s), interval [%.0f, %.0f]\n", ch.key.Profiler, ch.iteration, ch.key.Rate, ch.key.Formula, ch.stack, m.name, mean, m.want, stats.ErrorPercent(mean, m.want), lo, hi)
+		}
+	}
+	fmt.Fprintf(w, "%d streams, %d trials, %d checks, %d inconclusive, %d failures\n", iterations, trials, 2*len(checks), inconclusive, failures)
+	if failures > 0 {
+		return fmt.Errorf("%d checks failed", failures)
+	}
+	return nil
+}
+
+// fuzzStream returns a random allocation stream of profiler.FuzzStream, which
+// the fuzz tests of the profiler package also use.
+func fuzzStream(rnd *rand.Rand, rate int) *workload.AllocStream {
+	data := make([]byte, 2+rnd.Intn(1000))
+	rnd.Read(data)
+	stream := &workload.AllocStream{}
+	for _, run := range profiler.FuzzStream(rate, data) {
+		for i := int64(0); i < run.Count; i++ {
+			stream.Events = append(stream.Events, workload.AllocEvent{Size: run.Size, Stack: run.ID.Stack()})
+		}
+	}
+	return stream
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+func TestFuzzNoRates(t *testing.T) {
+	c := Cmd{Profilers: StringList{"go"}}
+	if err := c.Fuzz(io.Discard, 1); err == nil {
+		t.Error("fuzzing without rates succeeded")
+	}
+}
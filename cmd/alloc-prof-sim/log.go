@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger writes structured key=value lines for messages up to its Level. The
+// zero value discards all messages.
+type Logger struct {
+	W     io.Writer
+	Level int
+
+	mu sync.Mutex
+}
+
+// Log writes msg followed by the given key value pairs if level is enabled.
+func (l *Logger) Log(level int, msg string, kv ...interface{}) {
+	if l == nil || l.W == nil || level > l.Level {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("time=")
+	b.WriteString(time.Now().Format(time.RFC3339Nano))
+	b.WriteString(" msg=")
+	b.WriteString(logValue(msg))
+	for i := 0; i+1 < len(kv); i += 2 {
+		fmt.Fprintf(&b, " %v=%s", kv[i], logValue(kv[i+1]))
+	}
+	b.WriteString("\n")
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.W, b.String())
+}
+
+func logValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " =\"\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
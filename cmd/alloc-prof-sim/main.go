@@ -0,0 +1,590 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/felixge/alloc-prof-sim/engine"
+	"github.com/felixge/alloc-prof-sim/profiler"
+	"github.com/felixge/alloc-prof-sim/results"
+	"github.com/felixge/alloc-prof-sim/rng"
+	"github.com/felixge/alloc-prof-sim/stats"
+	"github.com/felixge/alloc-prof-sim/workload"
+)
+
+func main() {
+	cmd := Cmd{}
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: alloc-prof-sim [flags] [list|repl|serve [ADDR]|migrate|fuzz [ITERATIONS]|bias [ITERATIONS]|selftest [TRIALS]]\n")
+		flag.PrintDefaults()
+	}
+	flag.BoolVar(&cmd.Errors, "errors", false, "Report errors relative to perfect profiler instead of absolute numbers. Its stacks are mapped like those of the other profilers by -middleware, e.g. truncated by frames.")
+	flag.BoolVar(&cmd.Bench, "bench", false, "Report how fast each profiler simulates each workload instead of the results. Repeats each benchmark -trials times and reports the fastest run.")
+	flag.BoolVar(&cmd.Scale, "scale", true, "Scale sampled values to represent estimates of the true allocations.")
+	cmd.Formulas = ScaleFormulaList{profiler.ScaleHT}
+	flag.Var(&cmd.Formulas, "scale-formula", "Comma separated list of formulas for scaling sampled values: ht (each profiler's own inverse sampling probability), go, legacy, none or pprof (go as implemented by runtime/pprof).")
+	flag.Int64Var(&cmd.Seed, "seed", time.Now().UnixNano(), "Seed for random number generator.")
+	flag.IntVar(&cmd.Parallel, "parallel", 0, "Number of workloads to simulate concurrently. Defaults to the number of CPUs. Doesn't affect the results.")
+	flag.IntVar(&cmd.Shards, "shards", 1, "Split the ops of each workload into this many parts that are simulated concurrently with independent random streams. Results depend on the number of shards.")
+	flag.StringVar(&cmd.RNG, "rng", "wyrand", "Random number source: "+strings.Join(rng.Sources, " or ")+". Use go to reproduce results of versions before wyrand became the default.")
+	flag.StringVar(&cmd.Cache.Dir, "cache", "", "Directory for caching simulated profiles across runs. Disabled if empty.")
+	flag.StringVar(&cmd.Spill, "spill", "", "Directory for a temporary file that holds the results as they are simulated instead of memory, for sweeps too large to fit into it. Disabled if empty.")
+	flag.StringVar(&cmd.Manifest, "manifest", "", "Write a JSON manifest of the run to this file after its results, with the arguments, seeds, results schema, number of cells and whether the run completed or was interrupted. Disabled if empty.")
+	flag.IntVar(&cmd.Trials, "trials", 1, "Number of trials to run with different seeds derived from -seed.")
+	flag.BoolVar(&cmd.SizeClasses, "size-classes", false, "Round allocation sizes up to the size classes of the Go runtime before all profilers see them, and report the requested sizes as the "+engine.Requested+" profiler.")
+	flag.IntVar(&cmd.Overhead, "overhead", 0, "Bytes of allocator metadata, e.g. an object header, to add to each allocation before it is rounded to size classes. All profilers see these heap bytes, and the "+engine.Requested+" profiler reports the requested ones.")
+	flag.BoolVar(&cmd.Requested, "report-requested", false, "Make profilers report requested bytes while they sample on heap bytes, i.e. sizes with -overhead and rounded to -size-classes. The reference reports requested bytes then.")
+	flag.BoolVar(&cmd.Tiny, "tiny", false, "Combine allocations of less than 16 bytes into blocks like the tiny allocator of the Go runtime before all profilers except the reference see them, attributing each block to the allocation that started it.")
+	flag.StringVar(&cmd.Init, "init", string(profiler.InitZero), "Distance to the first sample of sampling profilers: zero (always sample the first allocation), rate or exp (drawn like the following ones).")
+	flag.BoolVar(&cmd.Warmup, "warmup", false, "Make sampling profilers count the first sample they always take with -init zero as the single allocation it is rather than scaling it.")
+	flag.BoolVar(&cmd.Histograms, "size-histograms", false, "Report a histogram of the objects of each stack by size in power-of-two buckets, e.g. 16:1000 for 1000 objects of 16 to 31 bytes. With -errors, report its error and the distance between the shapes of the histograms instead.")
+	flag.BoolVar(&cmd.PerP, "per-p", false, "Also run each profiler with a sampling state per P, like the mcaches of the Go runtime, and report it as NAME"+profiler.PerPSuffix+". Only the parallel workload runs on several Ps.")
+	flag.IntVar(&cmd.Procs, "procs", 4, "Number of Ps of the parallel workload.")
+	flag.Int64Var(&cmd.GCEvery, "gc-every", 0, "Simulate the end of a GC cycle each time this many bytes have been allocated, for GC aware profilers and middleware such as stage. Disabled if 0.")
+	flag.BoolVar(&cmd.MergeTrials, "merge-trials", false, "Report a single result per cell for all trials by merging their samples before scaling them.")
+	flag.DurationVar(&cmd.Costs.Alloc, "alloc-cost", 100*time.Nanosecond, "Simulated time the program spends per allocation without profiling, including its other work, for reporting the overhead of profilers.")
+	flag.DurationVar(&cmd.Costs.Check, "check-cost", time.Nanosecond, "Simulated time a profiler adds to every allocation.")
+	flag.DurationVar(&cmd.Costs.Sample, "sample-cost", 2*time.Microsecond, "Simulated time a profiler spends per sample, e.g. to unwind the stack.")
+	flag.Var(&cmd.TrialSeeds, "trial-seeds", "Comma separated list of trial seeds to run instead of deriving -trials seeds from -seed.")
+	cmd.Exp = IntList{8}
+	flag.Var(&cmd.Exp, "exp", "Repeat each workload 10^exp times. Accepts a comma separated list or a range such as 5-9.")
+	flag.DurationVar(&cmd.Duration, "duration", 0, "Run each cell for at most about this long instead of a fixed 10^exp ops.")
+	cmd.Workloads = StringList{"sequential", "interleave", "interleave-rand"}
+	flag.Var(&cmd.Workloads, "workload", "Comma separated list of workloads to run. Use stdin to read size,stack lines from standard input. Available: "+strings.Join(workloadNames(), ", ")+".")
+	cmd.Profilers = profilerNames()
+	flag.Var(&cmd.Profilers, "profiler", "Comma separated list of profilers to run. The "+engine.Reference+" profiler always runs. Available: "+strings.Join(cmd.Profilers, ", ")+".")
+	flag.Var(&cmd.Middleware, "middleware", "Comma separated list of middleware to wrap all profilers except "+engine.Reference+" with, given as NAME[=ARG]. Available: "+strings.Join(middlewareNames(), ", ")+".")
+	flag.Var(&cmd.WorkloadExp, "workload-exp", "Override -exp for workloads matching a glob, e.g. 'sequential-*=6'. May be repeated.")
+	cmd.Rate = IntList{100 * 1024}
+	flag.Var(&cmd.Rate, "rate", "Comma separated list of sampling rates in bytes. Accepts k, m and g suffixes and ranges with a step such as 64k-512k:64k.")
+	cmd.Small = IntList{16}
+	flag.Var(&cmd.Small, "small", "Comma separated list of small allocation sizes in bytes.")
+	cmd.Big = IntList{128}
+	flag.Var(&cmd.Big, "big", "Comma separated list of big allocation sizes in bytes.")
+	cmd.BigRate = FloatList{2}
+	flag.Var(&cmd.BigRate, "big-rate", "Comma separated list of big allocation sizes as multiples of the sampling rate.")
+	flag.Func("stacks", "Only report stacks matching this regular expression.", func(s string) (err error) {
+		cmd.Stacks, err = regexp.Compile(s)
+		return err
+	})
+	flag.Func("assert-max-error", "Exit with an error if any profiler's objects or bytes error for a stack exceeds this percentage, e.g. 5%.", func(s string) error {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return err
+		}
+		cmd.AssertMaxError = v
+		return nil
+	})
+	flag.Func("profiler-exec", "Register an external profiler as NAME=COMMAND [ARGS...] and run it. See profiler.Exec for the protocol. May be repeated.", func(s string) error {
+		name, command, ok := strings.Cut(s, "=")
+		if !ok || name == "" || len(strings.Fields(command)) == 0 {
+			return fmt.Errorf("want NAME=COMMAND: %q", s)
+		} else if _, ok := profiler.Lookup(name); ok {
+			return fmt.Errorf("profiler already exists: %q", name)
+		}
+		args := strings.Fields(command)
+		profiler.Register(name, profiler.Factory{
+			Params:      "rate, scale-formula, seed",
+			Description: "External profiler: " + command,
+			NoCache:     true,
+			New: func(c profiler.Config) profiler.Profiler {
+				return &profiler.Exec{ProfilerName: name, Command: args, Config: c, Stderr: os.Stderr}
+			},
+		})
+		cmd.Profilers = append(cmd.Profilers, name)
+		return nil
+	})
+	flag.StringVar(&cmd.Pprof.CPUProfile, "cpuprofile", "", "Write a CPU profile of the simulator to this file.")
+	flag.StringVar(&cmd.Pprof.MemProfile, "memprofile", "", "Write an allocation profile of the simulator to this file when it exits.")
+	flag.StringVar(&cmd.Pprof.Addr, "pprof", "", "Serve net/http/pprof on this address, e.g. localhost:6060, to profile the simulator while it runs.")
+	verbose := flag.Bool("v", false, "Log seeds, per-workload timing and per-cell sample counts to stderr.")
+	veryVerbose := flag.Bool("vv", false, "Like -v, but also log component seeds and cache lookups.")
+	flag.Parse()
+	cmd.Log.W = os.Stderr
+	if *veryVerbose {
+		cmd.Log.Level = 2
+	} else if *verbose {
+		cmd.Log.Level = 1
+	}
+	stopPprof, err := cmd.Pprof.Start(&cmd.Log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	switch flag.Arg(0) {
+	case "":
+		if cmd.Bench {
+			err = cmd.RunBench(os.Stdout)
+		} else {
+			err = cmd.Run()
+		}
+	case "list":
+		err = cmd.List(os.Stdout)
+	case "repl":
+		err = cmd.REPL(os.Stdin, os.Stdout, flag.CommandLine)
+	case "serve":
+		addr := "localhost:7070"
+		if flag.NArg() > 1 {
+			addr = flag.Arg(1)
+		}
+		err = cmd.Serve(addr)
+	case "migrate":
+		err = cmd.Migrate(os.Stdin, os.Stdout)
+	case "selftest":
+		trials := 30
+		if flag.NArg() > 1 {
+			if trials, err = strconv.Atoi(flag.Arg(1)); err != nil {
+				break
+			}
+		}
+		err = cmd.SelfTest(os.Stdout, trials)
+	case "fuzz", "bias":
+		iterations := 100
+		if flag.NArg() > 1 {
+			if iterations, err = strconv.Atoi(flag.Arg(1)); err != nil {
+				break
+			}
+		}
+		if flag.Arg(0) == "fuzz" {
+			err = cmd.Fuzz(os.Stdout, iterations)
+		} else {
+			err = cmd.Bias(os.Stdout, iterations)
+		}
+	default:
+		err = fmt.Errorf("unknown command: %q", flag.Arg(0))
+	}
+	if perr := stopPprof(); err == nil {
+		err = perr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+type Cmd struct {
+	Bench       bool
+	Scale       bool
+	Formulas    ScaleFormulaList
+	Exp         IntList
+	Workloads   StringList
+	Profilers   StringList
+	Middleware  StringList
+	WorkloadExp ExpOverrides
+	Duration    time.Duration
+	Seed        int64
+	Trials      int
+	MergeTrials bool
+	SizeClasses bool
+	Overhead    int
+	Requested   bool
+	Tiny        bool
+	Init        string
+	Warmup      bool
+	Histograms  bool
+	PerP        bool
+	Procs       int
+	GCEvery     int64
+	TrialSeeds  Int64List
+	Parallel    int
+	Shards      int
+	RNG         string
+	Cache       engine.Cache
+	Spill       string
+	Manifest    string
+	Errors      bool
+	Costs       stats.Costs
+	Rate        IntList
+	Small       IntList
+	Big         IntList
+	BigRate     FloatList
+	Stacks      *regexp.Regexp
+	// AssertMaxError is the maximum allowed absolute error in percent. It is
+	// disabled if zero.
+	AssertMaxError float64
+	Log            Logger
+	Pprof          Pprof
+
+	stdin *workload.AllocStream
+}
+
+func (c *Cmd) Run() error {
+	runner, err := c.runner()
+	if err != nil {
+		return err
+	}
+	trialSeeds, err := runner.TrialSeedList()
+	if err != nil {
+		return err
+	}
+	m := manifest{TrialSeeds: trialSeeds, Start: time.Now()}
+
+	// Simulate in the background so that an interrupt can still write out
+	// the results that were completed so far.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	var (
+		res store
+		run func() error
+	)
+	if c.Spill == "" {
+		mem := results.New()
+		res, run = mem, func() error { return runner.RunInto(mem) }
+	} else {
+		spill, err := results.CreateSpill(c.Spill)
+		if err != nil {
+			return err
+		}
+		defer spill.Close()
+		runner.OnResult = func(r results.Result) error {
+			spill.Add(r.Key, r.Profile)
+			return nil
+		}
+		res, run = spill, func() error { return runner.RunInto(nil) }
+	}
+	done := make(chan error, 1)
+	go func() { done <- run() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		} else if err := c.write(os.Stdout, res); err != nil {
+			return err
+		} else if err := c.writeManifest(m, res, true); err != nil {
+			return err
+		}
+		return c.assert(os.Stderr, res)
+	case <-interrupt:
+		// Holding the lock stops the simulation from adding more results
+		// while we write, and the process exits afterwards.
+		res.Lock()
+		c.Log.Log(1, "interrupted", "results", res.Len())
+		if err := c.write(os.Stdout, res); err != nil {
+			return err
+		} else if err := c.writeManifest(m, res, false); err != nil {
+			return err
+		}
+		return errors.New("interrupted")
+	}
+}
+
+// store holds the results of a run, either in memory or in a spill file.
+type store interface {
+	Lock()
+	Unlock()
+	Len() int
+	Get(results.Key) (profiler.Profile, bool)
+	Each(func(results.Result) error) error
+	UniqueStacks(workload string) []profiler.StackTrace
+}
+
+// runner reads any input and returns a runner for the configuration.
+func (c *Cmd) runner() (*engine.Runner, error) {
+	for _, name := range c.Workloads {
+		if name == "stdin" && c.stdin == nil {
+			stream, err := workload.ReadAllocStream(os.Stdin)
+			if err != nil {
+				return nil, fmt.Errorf("stdin: %w", err)
+			}
+			c.stdin = stream
+		}
+	}
+	formulas := c.Formulas
+	if !c.Scale {
+		formulas = ScaleFormulaList{profiler.ScaleNone}
+	}
+	return &engine.Runner{
+		Profilers:       c.Profilers,
+		Workloads:       c.Workloads,
+		Formulas:        formulas,
+		Rates:           c.Rate,
+		Small:           c.Small,
+		Big:             c.Big,
+		BigRate:         c.BigRate,
+		Exp:             c.Exp,
+		WorkloadExp:     c.WorkloadExp.Exp,
+		Duration:        c.Duration,
+		Seed:            c.Seed,
+		Trials:          c.Trials,
+		TrialSeeds:      c.TrialSeeds,
+		MergeTrials:     c.MergeTrials,
+		SizeClasses:     c.SizeClasses,
+		Overhead:        c.Overhead,
+		ReportRequested: c.Requested,
+		Tiny:            c.Tiny,
+		Init:            profiler.SampleInit(c.Init),
+		Warmup:          c.Warmup,
+		SizeHistograms:  c.Histograms,
+		PerP:            c.PerP,
+		Procs:           c.Procs,
+		GCEvery:         c.GCEvery,
+		Middleware:      c.Middleware,
+		Parallelism:     c.Parallel,
+		Shards:          c.Shards,
+		RNG:             c.RNG,
+		Stream:          c.stdin,
+		Cache:           c.Cache,
+		Log:             &c.Log,
+	}, nil
+}
+
+// write writes the results as CSV to w. The caller must not modify results
+// concurrently.
+func (c *Cmd) write(w io.Writer, res store) error {
+	cw, err := results.NewCSVWriter(w)
+	if err != nil {
+		return err
+	}
+	if err := c.eachRow(res, func(row results.Row) error {
+		cw.Write(row)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return cw.Flush()
+}
+
+// rows returns the results as table rows.
+func (c *Cmd) rows(res store) []results.Row {
+	var rows []results.Row
+	c.eachRow(res, func(row results.Row) error {
+		rows = append(rows, row)
+		return nil
+	})
+	return rows
+}
+
+// isReference reports whether name is the reference profiler or its copy
+// seeing the requested sizes, which aren't estimates to check against it.
+func isReference(name string) bool { return name == engine.Reference || name == engine.Requested }
+
+// eachRow calls fn with each table row of the results.
+func (c *Cmd) eachRow(res store, fn func(results.Row) error) error {
+	stacks := map[string][]profiler.StackTrace{}
+	reference := c.reference(res)
+	return res.Each(func(r results.Result) error {
+		if c.Errors && r.Profiler == engine.Reference {
+			return nil
+		}
+
+		sortedStacks, ok := stacks[r.Workload]
+		if !ok {
+			sortedStacks = res.UniqueStacks(r.Workload)
+			stacks[r.Workload] = sortedStacks
+		}
+		perfect := reference(r.Key)
+		// The reference profilers record every allocation rather than
+		// sampling them, so they have no meaningful overhead.
+		var overhead string
+		if !isReference(r.Profiler) {
+			overhead = fmt.Sprintf("%.2f%%", c.Costs.Overhead(perfect.Objects(), r.Profile.SampledObjects()))
+		}
+
+		for _, st := range sortedStacks {
+			if c.Stacks != nil && !c.Stacks.MatchString(string(st)) {
+				continue
+			}
+			got := r.Profile[st]
+			objects := fmt.Sprintf("%d", got.Objects)
+			bytes := fmt.Sprintf("%d", got.Bytes)
+			inuseObjects := fmt.Sprintf("%d", got.InUseObjects)
+			inuseBytes := fmt.Sprintf("%d", got.InUseBytes)
+			peakObjects := fmt.Sprintf("%d", got.PeakObjects)
+			peakBytes := fmt.Sprintf("%d", got.PeakBytes)
+			sizes, sizesDistance := got.Sizes.String(), ""
+			if c.Errors {
+				want := perfect[st]
+				objects = stats.ErrorPercent(float64(got.Objects), float64(want.Objects))
+				bytes = stats.ErrorPercent(float64(got.Bytes), float64(want.Bytes))
+				inuseObjects = stats.ErrorPercent(float64(got.InUseObjects), float64(want.InUseObjects))
+				inuseBytes = stats.ErrorPercent(float64(got.InUseBytes), float64(want.InUseBytes))
+				peakObjects = stats.ErrorPercent(float64(got.PeakObjects), float64(want.PeakObjects))
+				peakBytes = stats.ErrorPercent(float64(got.PeakBytes), float64(want.PeakBytes))
+				if n := len(want.Sizes); n > 0 {
+					if len(got.Sizes) > n {
+						n = len(got.Sizes)
+					}
+					g, w := got.Sizes.Floats(n), want.Sizes.Floats(n)
+					sizes = fmt.Sprintf("%.2f%%", stats.HistogramError(g, w))
+					sizesDistance = fmt.Sprintf("%.2f%%", stats.HistogramDistance(g, w))
+				}
+			}
+
+			if err := fn(results.Row{
+				Profiler: r.Profiler,
+				Workload: r.Workload,
+				Rate:     r.Rate,
+				Ops:      r.Ops,
+				Trial:    r.Trial,
+				Seed:     r.Seed,
+				Formula:  r.Formula,
+				Stack:    st,
+				Objects:  objects,
+				Bytes:    bytes,
+
+				InUseObjects: inuseObjects,
+				InUseBytes:   inuseBytes,
+
+				SampledObjects: fmt.Sprintf("%d", got.SampledObjects),
+				SampledBytes:   fmt.Sprintf("%d", got.SampledBytes),
+				Overhead:       overhead,
+
+				PeakObjects: peakObjects,
+				PeakBytes:   peakBytes,
+
+				Sizes:         sizes,
+				SizesDistance: sizesDistance,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// reference returns a function that returns the profile of the reference
+// profiler for the cell of a key. For the profilers wrapped by c.Middleware,
+// its stacks are mapped the same way, see profiler.MapProfile, so that e.g.
+// their truncated stacks are compared with the truncated reference instead of
+// stacks the reference doesn't have. It remembers the last profile, as the
+// results of a cell follow each other.
+func (c *Cmd) reference(res store) func(results.Key) profiler.Profile {
+	mws := c.middleware()
+	var (
+		lastKey          results.Key
+		last, lastMapped profiler.Profile
+	)
+	return func(key results.Key) profiler.Profile {
+		wrapped := !isReference(key.Profiler)
+		key.Profiler = engine.Reference
+		if key != lastKey || last == nil {
+			lastKey = key
+			last, _ = res.Get(key)
+			lastMapped = nil
+		}
+		if !wrapped || len(mws) == 0 {
+			return last
+		} else if lastMapped == nil {
+			lastMapped = profiler.MapProfile(last, mws...)
+		}
+		return lastMapped
+	}
+}
+
+// middleware returns the middleware of c.Middleware, skipping invalid ones,
+// which the engine rejects.
+func (c *Cmd) middleware() []profiler.Middleware {
+	var mws []profiler.Middleware
+	for _, m := range c.Middleware {
+		name, arg, _ := strings.Cut(m, "=")
+		spec, ok := profiler.LookupMiddleware(name)
+		if !ok {
+			continue
+		}
+		if mw, err := spec.New(arg, profiler.Config{Rand: rand.New(rand.NewSource(0))}); err == nil {
+			mws = append(mws, mw)
+		}
+	}
+	return mws
+}
+
+// Migrate converts results CSV of any schema version read from r to the
+// current version and writes it to w.
+func (c *Cmd) Migrate(r io.Reader, w io.Writer) error {
+	schema, rows, err := results.ReadCSV(r)
+	if err != nil {
+		return err
+	}
+	c.Log.Log(1, "migrate", "schema", schema, "rows", len(rows))
+	return results.WriteCSV(w, rows)
+}
+
+// assert reports all stacks whose error exceeds c.AssertMaxError to w and
+// returns an error if there are any.
+func (c *Cmd) assert(w io.Writer, res store) error {
+	if c.AssertMaxError == 0 || res.Len() == 0 {
+		return nil
+	}
+	var violations int
+	reference := c.reference(res)
+	if err := res.Each(func(r results.Result) error {
+		if isReference(r.Profiler) {
+			return nil
+		}
+		want := reference(r.Key)
+		for _, st := range res.UniqueStacks(r.Workload) {
+			if c.Stacks != nil && !c.Stacks.MatchString(string(st)) {
+				continue
+			}
+			objects := stats.RelError(float64(r.Profile[st].Objects), float64(want[st].Objects))
+			bytes := stats.RelError(float64(r.Profile[st].Bytes), float64(want[st].Bytes))
+			if math.Abs(objects) > c.AssertMaxError || math.Abs(bytes) > c.AssertMaxError {
+				violations++
+				fmt.Fprintf(w, "%s %s rate=%d ops=%d trial=%d stack=%s: objects %.2f%% bytes %.2f%%\n", r.Profiler, r.Workload, r.Rate, r.Ops, r.Trial, st, objects, bytes)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if violations > 0 {
+		return fmt.Errorf("%d stacks exceed max error of %.2f%%", violations, c.AssertMaxError)
+	}
+	return nil
+}
+
+func profilerNames() []string {
+	var names []string
+	for _, spec := range profiler.Specs() {
+		names = append(names, spec.Name)
+	}
+	return names
+}
+
+func middlewareNames() []string {
+	var names []string
+	for _, spec := range profiler.MiddlewareSpecs() {
+		names = append(names, spec.Name)
+	}
+	return names
+}
+
+func workloadNames() []string {
+	var names []string
+	for _, spec := range workload.Specs() {
+		names = append(names, spec.Name)
+	}
+	return names
+}
+
+// List writes a description of all available profilers and workloads to w.
+func (c *Cmd) List(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "PROFILER\tPARAMS\tDESCRIPTION\n")
+	for _, spec := range profiler.Specs() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", spec.Name, spec.Params, spec.Description)
+	}
+	fmt.Fprintf(tw, "\nMIDDLEWARE\tPARAMS\tDESCRIPTION\n")
+	for _, spec := range profiler.MiddlewareSpecs() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", spec.Name, spec.Params, spec.Description)
+	}
+	fmt.Fprintf(tw, "\nWORKLOAD\tPARAMS\tDESCRIPTION\n")
+	for _, spec := range workload.Specs() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", spec.Name, spec.Params, spec.Description)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	// Columns added after the input schema are left empty, or zero for
+	// numbers.
+	const want = `# schema=10
+profiler,workload,rate,ops,trial,seed,formula,stack,objects,bytes,inuse_objects,inuse_bytes,sampled_objects,sampled_bytes,overhead,peak_objects,peak_bytes,sizes,sizes_distance
+go,sequential,512,100,0,0,,main;alloc,10,1280,,,,,,,,,
+`
+	for _, tt := range []struct {
+		name, in, want string
+	}{{
+		name: "schema 3",
+		in: `# schema=3
+profiler,workload,rate,ops,stack,objects,bytes
+go,sequential,512,100,main;alloc,10,1280
+`,
+		want: want,
+	}, {
+		// Files written before versioning are identified by their header.
+		name: "unversioned",
+		in: `profiler,workload,rate,ops,stack,objects,bytes
+go,sequential,512,100,main;alloc,10,1280
+`,
+		want: want,
+	}, {
+		name: "current",
+		in:   want,
+		want: want,
+	}, {
+		name: "newer",
+		in: `# schema=11
+profiler,workload,rate,ops,trial,seed,formula,stack,objects,bytes,inuse_objects,inuse_bytes,sampled_objects,sampled_bytes,overhead,peak_objects,peak_bytes,sizes,sizes_distance,new
+go,sequential,512,100,0,0,,main;alloc,10,1280,,,,,,,,,,1
+`,
+	}} {
+		var c Cmd
+		var out bytes.Buffer
+		err := c.Migrate(strings.NewReader(tt.in), &out)
+		if tt.want == "" {
+			if err == nil || out.Len() > 0 {
+				t.Errorf("%s: migrating gives %v and %q, want an error and no output", tt.name, err, out.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if got := out.String(); got != tt.want {
+			t.Errorf("%s: migrating gives\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/felixge/alloc-prof-sim/results"
+)
+
+// manifest describes a run next to its results, so that they can be
+// reproduced and the partial results of an interrupted run told apart from
+// complete ones.
+type manifest struct {
+	Args       []string  `json:"args"`
+	Seed       int64     `json:"seed"`
+	TrialSeeds []int64   `json:"trialSeeds"`
+	Schema     int       `json:"schema"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	// Cells is the number of results written, each of which holds the
+	// rows of a profile.
+	Cells    int  `json:"cells"`
+	Complete bool `json:"complete"`
+}
+
+// writeManifest writes m to c.Manifest, if set, once the results of res have
+// been written.
+func (c *Cmd) writeManifest(m manifest, res store, complete bool) error {
+	if c.Manifest == "" {
+		return nil
+	}
+	m.Args = os.Args[1:]
+	m.Seed = c.Seed
+	m.Schema = results.Schema
+	m.End = time.Now()
+	m.Cells = res.Len()
+	m.Complete = complete
+	data, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.Manifest, append(data, '\n'), 0o666)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/felixge/alloc-prof-sim/results"
+)
+
+func TestWriteManifest(t *testing.T) {
+	c := Cmd{Manifest: filepath.Join(t.TempDir(), "manifest.json"), Seed: 3}
+	res := results.New()
+	res.Add(results.Key{Workload: "sequential", Profiler: "perfect"}, nil)
+	if err := c.writeManifest(manifest{TrialSeeds: []int64{5, 7}}, res, false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(c.Manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Seed != 3 || !reflect.DeepEqual(m.TrialSeeds, []int64{5, 7}) || m.Schema != results.Schema || m.Cells != 1 || m.Complete {
+		t.Errorf("got manifest %+v", m)
+	}
+}
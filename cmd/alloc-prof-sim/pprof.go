@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// Pprof configures profiling of the simulator itself.
+type Pprof struct {
+	// CPUProfile and MemProfile are files to write a CPU profile of the
+	// whole run and an allocation profile at its end to.
+	CPUProfile string
+	MemProfile string
+	// Addr is the address of an HTTP server for net/http/pprof, for
+	// profiling long runs while they are in progress.
+	Addr string
+}
+
+// Start starts the configured profiling and returns a function that stops it
+// and writes the profiles.
+func (p Pprof) Start(log *Logger) (stop func() error, err error) {
+	if p.Addr != "" {
+		lis, err := net.Listen("tcp", p.Addr)
+		if err != nil {
+			return nil, err
+		}
+		log.Log(0, "pprof", "url", "http://"+lis.Addr().String()+"/debug/pprof/")
+		go http.Serve(lis, nil)
+	}
+
+	var cpu *os.File
+	if p.CPUProfile != "" {
+		if cpu, err = os.Create(p.CPUProfile); err != nil {
+			return nil, err
+		} else if err := pprof.StartCPUProfile(cpu); err != nil {
+			cpu.Close()
+			return nil, err
+		}
+	}
+
+	return func() error {
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			if err := cpu.Close(); err != nil {
+				return err
+			}
+		}
+		if p.MemProfile == "" {
+			return nil
+		}
+		f, err := os.Create(p.MemProfile)
+		if err != nil {
+			return err
+		}
+		// Include the most recent allocations in the in-use values.
+		runtime.GC()
+		if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}, nil
+}
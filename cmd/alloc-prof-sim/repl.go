@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+	"github.com/felixge/alloc-prof-sim/results"
+	"github.com/felixge/alloc-prof-sim/stats"
+)
+
+const replHelp = `commands:
+  set FLAG [VALUE]          set a flag, e.g. set rate 4096,8192
+  show                      show all flag values
+  run [WORKLOAD [PROFILER]] run cells matching the workload and profiler globs
+  columns short|all         print the error of the estimated bytes across
+                            trials after each run, the default, or all columns
+  help                      show this help
+  quit                      exit
+`
+
+// REPL reads commands from in that modify the flags and run simulations,
+// printing compact result tables to out.
+func (c *Cmd) REPL(in io.Reader, out io.Writer, flags *flag.FlagSet) error {
+	var all bool
+	s := bufio.NewScanner(in)
+	fmt.Fprint(out, "> ")
+	for s.Scan() {
+		args := strings.Fields(s.Text())
+		if len(args) > 0 {
+			if args[0] == "quit" || args[0] == "exit" {
+				return nil
+			} else if err := c.replCommand(out, flags, args, &all); err != nil {
+				fmt.Fprintf(out, "error: %s\n", err)
+			}
+		}
+		fmt.Fprint(out, "> ")
+	}
+	return s.Err()
+}
+
+// replCommand runs the command args. all selects whether run prints all
+// columns, which the columns command sets.
+func (c *Cmd) replCommand(out io.Writer, flags *flag.FlagSet, args []string, all *bool) error {
+	switch args[0] {
+	case "help":
+		fmt.Fprint(out, replHelp)
+	case "set":
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("usage: set FLAG [VALUE]")
+		}
+		return flags.Set(args[1], strings.Join(args[2:], ""))
+	case "show":
+		tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		flags.VisitAll(func(f *flag.Flag) { fmt.Fprintf(tw, "%s\t%s\n", f.Name, f.Value) })
+		return tw.Flush()
+	case "columns":
+		if len(args) != 2 || (args[1] != "short" && args[1] != "all") {
+			return fmt.Errorf("usage: columns short|all")
+		}
+		*all = args[1] == "all"
+	case "run":
+		if len(args) > 3 {
+			return fmt.Errorf("usage: run [WORKLOAD [PROFILER]]")
+		}
+		for _, name := range c.Workloads {
+			if name == "stdin" {
+				return fmt.Errorf("the stdin workload is not supported interactively")
+			}
+		}
+		runner, err := c.runner()
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			runner.OnlyWorkloads = args[1]
+		}
+		if len(args) > 2 {
+			runner.OnlyProfilers = args[2]
+		}
+		res, err := runner.Run()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 8, 2, ' ', tabwriter.AlignRight)
+		if *all {
+			fmt.Fprintf(tw, "%s\t\n", strings.Join(results.Columns(), "\t"))
+			for _, row := range c.rows(res) {
+				fmt.Fprintf(tw, "%s\t\n", strings.Join(row.Strings(), "\t"))
+			}
+		} else {
+			c.replSummary(tw, res)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		return c.assert(out, res)
+	default:
+		return fmt.Errorf("unknown command: %q, try help", args[0])
+	}
+	return nil
+}
+
+// replSummary writes a row per cell and stack of res to w with the mean error
+// of the estimated bytes across trials and its 95% confidence interval, which
+// needs at least two trials.
+func (c *Cmd) replSummary(w io.Writer, res *results.Results) {
+	type summaryKey struct {
+		workload, profiler string
+		rate               int
+		formula            profiler.ScaleFormula
+		stack              profiler.StackTrace
+	}
+	errors := map[summaryKey][]float64{}
+	reference := c.reference(res)
+	for _, r := range res.List {
+		if isReference(r.Profiler) {
+			continue
+		}
+		want := reference(r.Key)
+		for st, a := range want {
+			if c.Stacks != nil && !c.Stacks.MatchString(string(st)) {
+				continue
+			}
+			k := summaryKey{r.Workload, r.Profiler, r.Rate, r.Formula, st}
+			errors[k] = append(errors[k], stats.RelError(float64(r.Profile[st].Bytes), float64(a.Bytes)))
+		}
+	}
+	keys := make([]summaryKey, 0, len(errors))
+	for k := range errors {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.workload != b.workload {
+			return a.workload < b.workload
+		} else if a.rate != b.rate {
+			return a.rate < b.rate
+		} else if a.profiler != b.profiler {
+			return a.profiler < b.profiler
+		} else if a.formula != b.formula {
+			return a.formula < b.formula
+		}
+		return a.stack < b.stack
+	})
+
+	fmt.Fprintf(w, "profiler\tworkload\trate\tstack\tbytes error\tinterval\t\n")
+	for _, k := range keys {
+		name := k.profiler
+		if len(c.Formulas) > 1 {
+			name += "/" + string(k.formula)
+		}
+		mean, lo, hi := stats.MeanCI(errors[k], 0.95)
+		interval := ""
+		if !math.IsNaN(lo) {
+			interval = fmt.Sprintf("[%.2f%%, %.2f%%]", lo, hi)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%.2f%%\t%s\t\n", name, k.workload, k.rate, k.stack, mean, interval)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/felixge/alloc-prof-sim/engine"
+	"github.com/felixge/alloc-prof-sim/profiler"
+	"github.com/felixge/alloc-prof-sim/results"
+	"github.com/felixge/alloc-prof-sim/stats"
+)
+
+// selfTestWorkloads are the canonical workloads of SelfTest. Each of their
+// stacks allocates a single size.
+var selfTestWorkloads = []string{"sequential", "interleave", "interleave-rand", "churn", "parallel", "deep"}
+
+const (
+	selfTestRate = 4096
+	selfTestExp  = 5
+)
+
+// SelfTest runs the selected profilers that claim to be unbiased, including
+// their copies with a state per P, over the canonical workloads for the given
+// number of trials with the rate selfTestRate and the sizes of -small and
+// -big. Like Fuzz, it checks for each
+// stack that the true objects and bytes, and the in-use ones if the workload
+// frees objects, lie within the Bonferroni corrected confidence interval of
+// the mean estimate across trials. Like Bias, it tolerates errors within
+// -assert-max-error, or 1% if unset, such as those of deterministic samplers
+// or of sampling the first allocation. Failures are reported to w, and an
+// error is returned if there are any, so it can guard the estimators against
+// regressions.
+func (c *Cmd) SelfTest(w io.Writer, trials int) error {
+	tolerance := c.AssertMaxError
+	if tolerance == 0 {
+		tolerance = 1
+	}
+	var profilers []string
+	for _, name := range c.Profilers {
+		if spec, ok := profiler.Lookup(name); ok && name != engine.Reference && !spec.Biased {
+			profilers = append(profilers, name)
+		}
+	}
+	runner, err := c.runner()
+	if err != nil {
+		return err
+	}
+	runner.Workloads = selfTestWorkloads
+	runner.Profilers = profilers
+	runner.Rates = []int{selfTestRate}
+	// Sizes beyond the rate bias go, whose sampling distance doesn't carry
+	// over the bytes of a sampled allocation, see go-remainder.
+	runner.BigRate = nil
+	runner.Exp = []int{selfTestExp}
+	runner.WorkloadExp, runner.Duration = nil, 0
+	runner.Formulas = runner.Formulas[:1]
+	runner.PerP = true
+	runner.Trials, runner.TrialSeeds = trials, nil
+	res, err := runner.Run()
+	if err != nil {
+		return err
+	}
+
+	// Random workloads differ between trials, so each check holds the
+	// estimates and true values of all trials.
+	type check struct {
+		key       results.Key
+		stack     profiler.StackTrace
+		got, want [4][]float64
+		frees     bool
+	}
+	var (
+		checks []*check
+		cells  = map[results.Key]map[profiler.StackTrace]*check{}
+	)
+	for _, r := range res.List {
+		if isReference(r.Profiler) {
+			continue
+		}
+		want := res.Index[results.Key{Workload: r.Workload, Profiler: engine.Reference, Rate: r.Rate, Ops: r.Ops, Trial: r.Trial, Seed: r.Seed, Formula: r.Formula}]
+		cell := r.Key
+		cell.Trial, cell.Seed = 0, 0
+		if cells[cell] == nil {
+			cells[cell] = map[profiler.StackTrace]*check{}
+		}
+		for st, a := range want {
+			ch := cells[cell][st]
+			if ch == nil {
+				ch = &check{key: cell, stack: st}
+				cells[cell][st] = ch
+				checks = append(checks, ch)
+			}
+			got := r.Profile[st]
+			for i, v := range []int64{got.Objects, got.Bytes, got.InUseObjects, got.InUseBytes} {
+				ch.got[i] = append(ch.got[i], float64(v))
+			}
+			for i, v := range []int64{a.Objects, a.Bytes, a.InUseObjects, a.InUseBytes} {
+				ch.want[i] = append(ch.want[i], float64(v))
+			}
+			ch.frees = ch.frees || a.InUseObjects != a.Objects
+		}
+	}
+
+	// Each check compares objects and bytes, and in-use ones if anything
+	// was freed.
+	var n int
+	for _, ch := range checks {
+		n += 2
+		if ch.frees {
+			n += 2
+		}
+	}
+	level := 1 - fuzzAlpha/float64(n)
+	var failures, inconclusive int
+	for _, ch := range checks {
+		for i, name := range []string{"objects", "bytes", "inuse_objects", "inuse_bytes"} {
+			if i >= 2 && !ch.frees {
+				break
+			}
+			// The estimates are shifted by the deviation of the true
+			// value of their trial from the mean one.
+			want := stats.Mean(ch.want[i])
+			got := make([]float64, len(ch.got[i]))
+			for j := range got {
+				got[j] = ch.got[i][j] - ch.want[i][j] + want
+			}
+			mean, lo, hi, result := stats.CheckMean(got, want, level)
+			if result == stats.CheckFailed && lo <= want*(1+tolerance/100) && hi >= want*(1-tolerance/100) {
+				result = stats.CheckPassed
+			}
+			if result == stats.CheckPassed {
+				continue
+			} else if result == stats.CheckInconclusive {
+				inconclusive++
+				continue
+			}
+			failures++
+			fmt.Fprintf(w, "%s workload=%s stack=%s: %s mean %.0f want %.0f (%s), interval [%.0f, %.0f]\n", ch.key.Profiler, ch.key.Workload, ch.stack, name, mean, want, stats.ErrorPercent(mean, want), lo, hi)
+		}
+	}
+	fmt.Fprintf(w, "%d profilers, %d workloads, %d trials, %d checks, %d inconclusive, %d failures beyond %.2f%%\n", len(profilers), len(selfTestWorkloads), trials, n, inconclusive, failures, tolerance)
+	if failures > 0 {
+		return fmt.Errorf("%d checks failed", failures)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+func TestSelfTest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("simulates every unbiased profiler on the canonical workloads")
+	}
+	c := Cmd{
+		Profilers: profilerNames(),
+		Formulas:  ScaleFormulaList{profiler.ScaleHT},
+		Scale:     true,
+		Seed:      1,
+		Small:     IntList{16},
+		Big:       IntList{128},
+		Procs:     4,
+		Shards:    1,
+		RNG:       "wyrand",
+	}
+	var out strings.Builder
+	if err := c.SelfTest(&out, 30); err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	t.Log(strings.TrimSpace(out.String()))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+
+	"google.golang.org/grpc"
+
+	"github.com/felixge/alloc-prof-sim/engine"
+	"github.com/felixge/alloc-prof-sim/results"
+)
+
+// Serve exposes the simulation engine as the gRPC service
+// allocprofsim.Simulator on addr until the listener fails. Messages are
+// encoded as JSON instead of protocol buffers, so clients don't need generated
+// code:
+//
+//	rpc List(Empty) returns (engine.Catalog)
+//	rpc Run(engine.Config) returns (stream results.Result)
+//
+// Run streams the results of each trial as it completes. From Python:
+//
+//	channel = grpc.insecure_channel("localhost:7070")
+//	run = channel.unary_stream("/allocprofsim.Simulator/Run",
+//		request_serializer=lambda c: json.dumps(c).encode(),
+//		response_deserializer=json.loads)
+//	for result in run({"profilers": ["go"], "exp": [6], "trials": 10}):
+//		print(result["profiler"], result["profile"])
+func (c *Cmd) Serve(addr string) error {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	c.Log.Log(0, "serving", "addr", lis.Addr())
+	srv := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	srv.RegisterService(&simulatorDesc, &simulator{cmd: c})
+	return srv.Serve(lis)
+}
+
+type simulator struct {
+	cmd *Cmd
+}
+
+func (s *simulator) list(ctx context.Context, _ *struct{}) (*engine.Catalog, error) {
+	catalog := engine.List()
+	return &catalog, nil
+}
+
+func (s *simulator) run(config *engine.Config, stream grpc.ServerStream) error {
+	runner := config.Runner()
+	runner.Cache = s.cmd.Cache
+	runner.Log = &s.cmd.Log
+	runner.OnResult = func(r results.Result) error {
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+		return stream.SendMsg(&r)
+	}
+	s.cmd.Log.Log(1, "run", "config", config)
+	_, err := runner.Run()
+	return err
+}
+
+var simulatorDesc = grpc.ServiceDesc{
+	ServiceName: "allocprofsim.Simulator",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "List",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(struct{})
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			return srv.(*simulator).list(ctx, in)
+		},
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "Run",
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			config := new(engine.Config)
+			if err := stream.RecvMsg(config); err != nil {
+				return err
+			}
+			return srv.(*simulator).run(config, stream)
+		},
+	}},
+}
+
+// jsonCodec encodes gRPC messages as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                          { return "json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+// Baseline is the profiler name of benchmarks that simulate a workload without
+// profiling it, which measures the cost of the workload itself.
+const Baseline = "-"
+
+// BenchResult is the speed of simulating a workload with a profiler.
+type BenchResult struct {
+	Workload string
+	Profiler string
+	Rate     int
+	Ops      int64
+	// Allocs is the number of allocations in the workload, as counted by the
+	// reference profiler.
+	Allocs   int64
+	Duration time.Duration
+}
+
+// OpsPerSec returns the number of workload ops simulated per second.
+func (b BenchResult) OpsPerSec() float64 { return float64(b.Ops) / b.Duration.Seconds() }
+
+// AllocsPerSec returns the number of allocations simulated per second.
+func (b BenchResult) AllocsPerSec() float64 { return float64(b.Allocs) / b.Duration.Seconds() }
+
+// Bench measures how fast each profiler simulates each workload. Unlike Run it
+// simulates every profiler on its own, one at a time and without the cache,
+// and adds a Baseline result for each workload. Each trial repeats all
+// benchmarks and the fastest run is reported. Scale formulas don't affect the
+// simulation, so only the first one is used.
+func (r *Runner) Bench() ([]BenchResult, error) {
+	trialSeeds, err := r.TrialSeedList()
+	if err != nil {
+		return nil, err
+	}
+	br := *r
+	br.Cache = Cache{}
+	br.Shards = 0
+	br.Formulas = br.formulas()[:1]
+
+	var (
+		list  []BenchResult
+		index = map[BenchResult]int{}
+	)
+	add := func(b BenchResult) {
+		key := b
+		key.Allocs, key.Duration = 0, 0
+		if i, ok := index[key]; !ok {
+			index[key] = len(list)
+			list = append(list, b)
+		} else if b.Duration < list[i].Duration {
+			list[i] = b
+		}
+	}
+	for trial, seed := range trialSeeds {
+		for _, g := range br.plan(trial, seed).groups {
+			var (
+				allocs int64
+				rates  []int
+				byRate = map[int][]BenchResult{}
+			)
+			for _, c := range g.cells {
+				w := g.workload.New()
+				start := time.Now()
+				w.Work(g.ops, profiler.AsIDProfiler(r.triggerGC(r.allocator(c.key, c.profiler, frees(w)))))
+				d := time.Since(start)
+				if e, ok := c.profiler.(interface{ Err() error }); ok && e.Err() != nil {
+					return nil, e.Err()
+				} else if c.key.Profiler == Reference {
+					allocs = c.profiler.Profile().Objects()
+				}
+				if _, ok := byRate[c.key.Rate]; !ok {
+					rates = append(rates, c.key.Rate)
+				}
+				byRate[c.key.Rate] = append(byRate[c.key.Rate], BenchResult{Workload: c.key.Workload, Profiler: c.key.Profiler, Rate: c.key.Rate, Ops: g.ops, Duration: d})
+				r.log(1, "bench done", "workload", c.key.Workload, "profiler", c.key.Profiler, "rate", c.key.Rate, "ops", g.ops, "trial", trial, "duration", d)
+			}
+			for _, rate := range rates {
+				bs := byRate[rate]
+				w := g.workload.New()
+				start := time.Now()
+				w.Work(g.ops, discard{})
+				bs = append([]BenchResult{{Workload: bs[0].Workload, Profiler: Baseline, Rate: rate, Ops: g.ops, Duration: time.Since(start)}}, bs...)
+				for _, b := range bs {
+					b.Allocs = allocs
+					add(b)
+				}
+			}
+		}
+	}
+	return list, nil
+}
+
+// discard is a profiler that ignores all allocations.
+type discard struct{}
+
+func (discard) Name() string                                       { return Baseline }
+func (discard) Malloc(size int, stack profiler.StackTrace)         {}
+func (discard) MallocID(size int, id profiler.StackID)             {}
+func (discard) MallocN(size int, count int64, id profiler.StackID) {}
+func (discard) Profile() profiler.Profile                          { return nil }
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+// cacheVersion must be incremented whenever a change to the simulation
+// invalidates previously cached profiles in a way that is not captured by the
+// version of a profiler or workload spec.
+const cacheVersion = 6
+
+// Cache stores simulated profiles on disk, addressed by a hash of all inputs
+// that determine them. A Cache with an empty Dir is disabled.
+type Cache struct {
+	Dir string
+}
+
+// CacheKey holds all inputs that determine the profile of a simulation.
+type CacheKey struct {
+	Version         int
+	Profiler        string
+	ProfilerVersion int
+	Workload        string
+	WorkloadVersion int
+	// Input identifies external workload input such as a stream read from
+	// stdin.
+	Input   string
+	Rate    int
+	Ops     int64
+	Formula profiler.ScaleFormula
+	Seed    int64
+	// Middleware, RNG, Shards, SizeClasses, Overhead, ReportRequested, Tiny,
+	// Init, Warmup, SizeHistograms and GCEvery are omitted if empty
+	// to keep the keys of earlier versions, which always used the go source
+	// and a single shard.
+	Middleware      []string `json:",omitempty"`
+	RNG             string   `json:",omitempty"`
+	Shards          int      `json:",omitempty"`
+	SizeClasses     bool     `json:",omitempty"`
+	Overhead        int      `json:",omitempty"`
+	ReportRequested bool     `json:",omitempty"`
+	Tiny            bool     `json:",omitempty"`
+	Init            string   `json:",omitempty"`
+	Warmup          bool     `json:",omitempty"`
+	SizeHistograms  bool     `json:",omitempty"`
+	GCEvery         int64    `json:",omitempty"`
+}
+
+// Get returns the cached profile for key. Missing or unreadable entries are
+// reported as cache misses.
+func (c Cache) Get(key CacheKey) (profiler.Profile, bool) {
+	if c.Dir == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var profile profiler.Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, false
+	}
+	return profile, true
+}
+
+// Put stores the profile for key.
+func (c Cache) Put(key CacheKey, profile profiler.Profile) error {
+	if c.Dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so concurrent or interrupted runs never
+	// leave a partial entry behind.
+	tmp, err := os.CreateTemp(c.Dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	} else if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+func (c Cache) path(key CacheKey) string {
+	key.Version = cacheVersion
+	data, _ := json.Marshal(key)
+	sum := sha256.Sum256(data)
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
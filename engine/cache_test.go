@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/felixge/alloc-prof-sim/results"
+)
+
+// TestCacheRequestedSizes checks that the reference profile of a run reporting
+// requested sizes, which sees them rather than the heap sizes, isn't taken
+// from the cache by a run that doesn't.
+func TestCacheRequestedSizes(t *testing.T) {
+	dir := t.TempDir()
+	run := func(opts ...Option) *results.Results {
+		opts = append([]Option{
+			WithProfilers(Reference),
+			WithWorkloads("sequential"),
+			WithSizes([]int{20}, []int{130}),
+			WithExp(3),
+			WithSeed(1),
+			WithSizeClasses(),
+		}, opts...)
+		res, err := New(opts...).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	reference := func(res *results.Results) int64 {
+		for _, r := range res.List {
+			if r.Profiler == Reference {
+				return r.Profile["big"].Bytes
+			}
+		}
+		t.Fatal("no reference result")
+		return 0
+	}
+
+	uncached := reference(run())
+	run(WithCache(dir), WithReportRequested())
+	if got := reference(run(WithCache(dir))); got != uncached {
+		t.Errorf("cached run gives %d bytes of big objects, uncached %d", got, uncached)
+	}
+	if uncached != 1000*144 {
+		t.Errorf("got %d bytes of big objects, want them rounded to the size class", uncached)
+	}
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"github.com/felixge/alloc-prof-sim/profiler"
+	"github.com/felixge/alloc-prof-sim/workload"
+)
+
+// Config is a JSON representation of the configuration of a Runner, e.g. for
+// remote or browser clients. Omitted fields use the defaults of New.
+type Config struct {
+	Profilers       []string                `json:"profilers,omitempty"`
+	Workloads       []string                `json:"workloads,omitempty"`
+	Middleware      []string                `json:"middleware,omitempty"`
+	Formulas        []profiler.ScaleFormula `json:"formulas,omitempty"`
+	Rates           []int                   `json:"rates,omitempty"`
+	Small           []int                   `json:"small,omitempty"`
+	Big             []int                   `json:"big,omitempty"`
+	BigRate         []float64               `json:"bigRate,omitempty"`
+	Exp             []int                   `json:"exp,omitempty"`
+	Seed            int64                   `json:"seed,omitempty"`
+	Trials          int                     `json:"trials,omitempty"`
+	TrialSeeds      []int64                 `json:"trialSeeds,omitempty"`
+	RNG             string                  `json:"rng,omitempty"`
+	Shards          int                     `json:"shards,omitempty"`
+	MergeTrials     bool                    `json:"mergeTrials,omitempty"`
+	SizeClasses     bool                    `json:"sizeClasses,omitempty"`
+	Overhead        int                     `json:"overhead,omitempty"`
+	ReportRequested bool                    `json:"reportRequested,omitempty"`
+	Tiny            bool                    `json:"tiny,omitempty"`
+	Init            profiler.SampleInit     `json:"init,omitempty"`
+	Warmup          bool                    `json:"warmup,omitempty"`
+	SizeHistograms  bool                    `json:"sizeHistograms,omitempty"`
+	PerP            bool                    `json:"perP,omitempty"`
+	Procs           int                     `json:"procs,omitempty"`
+	GCEvery         int64                   `json:"gcEvery,omitempty"`
+}
+
+// Runner returns a runner for the configuration.
+func (c Config) Runner() *Runner {
+	r := New(WithSeed(c.Seed))
+	if c.Profilers != nil {
+		r.Profilers = c.Profilers
+	}
+	if c.Workloads != nil {
+		r.Workloads = c.Workloads
+	}
+	if c.Middleware != nil {
+		r.Middleware = c.Middleware
+	}
+	if c.Formulas != nil {
+		r.Formulas = c.Formulas
+	}
+	if c.Rates != nil {
+		r.Rates = c.Rates
+	}
+	if c.Small != nil {
+		r.Small = c.Small
+	}
+	if c.Big != nil {
+		r.Big = c.Big
+	}
+	if c.BigRate != nil {
+		r.BigRate = c.BigRate
+	}
+	if c.Exp != nil {
+		r.Exp = c.Exp
+	}
+	if c.Trials > 0 {
+		r.Trials = c.Trials
+	}
+	r.TrialSeeds = c.TrialSeeds
+	r.RNG = c.RNG
+	r.Shards = c.Shards
+	r.MergeTrials = c.MergeTrials
+	r.SizeClasses = c.SizeClasses
+	r.Overhead = c.Overhead
+	r.ReportRequested = c.ReportRequested
+	r.Tiny = c.Tiny
+	r.Init = c.Init
+	r.Warmup = c.Warmup
+	r.SizeHistograms = c.SizeHistograms
+	r.PerP = c.PerP
+	r.Procs = c.Procs
+	r.GCEvery = c.GCEvery
+	return r
+}
+
+// Component describes a registered profiler, middleware or workload.
+type Component struct {
+	Name        string `json:"name"`
+	Params      string `json:"params"`
+	Description string `json:"description"`
+}
+
+// Catalog lists all registered components.
+type Catalog struct {
+	Profilers  []Component `json:"profilers"`
+	Middleware []Component `json:"middleware"`
+	Workloads  []Component `json:"workloads"`
+}
+
+// List returns all registered components. Workloads that need external input
+// are omitted.
+func List() Catalog {
+	var c Catalog
+	for _, spec := range profiler.Specs() {
+		c.Profilers = append(c.Profilers, Component{spec.Name, spec.Params, spec.Description})
+	}
+	for _, spec := range profiler.MiddlewareSpecs() {
+		c.Middleware = append(c.Middleware, Component{spec.Name, spec.Params, spec.Description})
+	}
+	for _, spec := range workload.Specs() {
+		if spec.Name != "stdin" {
+			c.Workloads = append(c.Workloads, Component{spec.Name, spec.Params, spec.Description})
+		}
+	}
+	return c
+}
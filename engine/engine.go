@@ -0,0 +1,847 @@
+// Package engine simulates profilers against workloads.
+//
+// The exported API of this package and of the profiler, workload, results and
+// stats packages follows semantic versioning: within a major version, exported
+// identifiers are neither removed nor changed incompatibly, and options are
+// only ever added. The command line tool and its output are not covered.
+// Simulated values may change between versions when a profiler or workload
+// model is corrected, which is reflected in its registered Version.
+package engine
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+	"github.com/felixge/alloc-prof-sim/results"
+	"github.com/felixge/alloc-prof-sim/rng"
+	"github.com/felixge/alloc-prof-sim/workload"
+)
+
+// Reference is the profiler that errors are reported relative to. It always
+// runs.
+const Reference = "perfect"
+
+// Requested is the name of a copy of the reference profiler that sees the
+// requested sizes of allocations while all other profilers see them rounded
+// to size classes, see Runner.SizeClasses.
+const Requested = "requested"
+
+// Logger receives structured log messages with alternating key value pairs.
+type Logger interface {
+	Log(level int, msg string, kv ...interface{})
+}
+
+// Runner simulates every selected profiler against every selected workload
+// for each rate, trial, number of ops and scale formula.
+type Runner struct {
+	// Profilers and Workloads are the names of registered components.
+	Profilers []string
+	Workloads []string
+	// Formulas defaults to profiler.ScaleHT.
+	Formulas []profiler.ScaleFormula
+	Rates    []int
+	Small    []int
+	Big      []int
+	// BigRate holds additional big allocation sizes as multiples of the rate.
+	BigRate []float64
+	// Exp holds the number of ops to run each workload as powers of ten.
+	// Workloads with a finite length default to running it instead.
+	Exp []int
+	// WorkloadExp optionally overrides Exp for individual workloads.
+	WorkloadExp func(workload string) ([]int, bool)
+	// Duration runs each cell for about this long instead of using Exp if
+	// non-zero.
+	Duration time.Duration
+	// TrialSeeds holds the seed of each trial. If empty, Trials seeds are
+	// derived from Seed.
+	Seed       int64
+	Trials     int
+	TrialSeeds []int64
+	// Middleware wraps every profiler except the reference with the
+	// registered middleware given as NAME[=ARG], in order.
+	Middleware []string
+	// RNG is the name of the random number source, see rng.New.
+	RNG string
+	// SizeClasses rounds the size of each allocation up to the size classes
+	// of the Go runtime before all profilers, including the reference, see
+	// it. A Requested result reports the allocations at their requested
+	// sizes.
+	SizeClasses bool
+	// Overhead is the number of bytes of allocator metadata, e.g. an object
+	// header, added to each allocation before it is rounded to size classes.
+	// All profilers, including the reference, see these heap bytes, and a
+	// Requested result reports the requested ones.
+	Overhead int
+	// ReportRequested makes all profilers except the reference report
+	// requested bytes while they still sample on heap bytes, see
+	// profiler.ReportRequested. The reference sees the requested sizes then
+	// and there is no Requested result. Such profilers aren't split into
+	// shards.
+	ReportRequested bool
+	// Tiny combines allocations of less than profiler.TinySize bytes into
+	// blocks like the tiny allocator of the Go runtime before all profilers
+	// except the reference and Requested see them, see profiler.TinyAlloc.
+	// Allocations are rounded to size classes after that.
+	Tiny bool
+	// Init selects the distance to the first sample of sampling profilers,
+	// see profiler.WithInit. Empty means profiler.InitZero.
+	Init profiler.SampleInit
+	// Warmup makes sampling profilers starting with a distance of zero count
+	// their certain first sample as is, see profiler.WithWarmup.
+	Warmup bool
+	// SizeHistograms makes all profilers, including the reference, report
+	// a histogram of the sizes of each stack, see
+	// profiler.WithSizeHistograms.
+	SizeHistograms bool
+	// PerP additionally simulates each selected profiler except the
+	// reference with a state per P, see profiler.PerP, and reports it as
+	// the profiler's name with profiler.PerPSuffix. Only workloads running
+	// goroutines on several Ps, e.g. parallel, tell the profilers which P
+	// allocates.
+	PerP bool
+	// Procs is the number of Ps of such workloads. It defaults to 4.
+	Procs int
+	// GCEvery simulates the end of a GC cycle each time this many bytes
+	// have been allocated, counting the requested sizes, in addition to
+	// those of the workload, see profiler.TriggerGC. Each shard counts its
+	// own bytes.
+	GCEvery int64
+	// Stream is the input of the stdin workload.
+	Stream *workload.AllocStream
+	Cache  Cache
+	Log    Logger
+
+	// Parallelism is the number of workloads simulated concurrently. It
+	// defaults to GOMAXPROCS.
+	Parallelism int
+	// Shards splits the ops of each workload into this many parts that are
+	// simulated concurrently with independent random streams, see
+	// rng.NewStream. The samples of the parts are merged before they are
+	// estimated. Results depend on Shards, but not on Parallelism. Workloads
+	// that don't implement workload.Ranger or that free objects, and those
+	// simulated by a profiler that doesn't implement profiler.Merger aren't
+	// split.
+	Shards int
+	// OnResult is called with each result after it was added. Returning an
+	// error aborts the run.
+	OnResult func(results.Result) error
+
+	// MergeTrials reports a single result per cell for all trials, as if they
+	// were parts of one run. The samples of all trials are merged before
+	// they are estimated, except for profilers that don't implement
+	// profiler.Merger, whose estimates are added up instead. The result has
+	// the key of the first trial with the ops of all trials. The cache isn't
+	// used.
+	MergeTrials bool
+
+	// OnlyWorkloads and OnlyProfilers are globs that restrict which cells
+	// are simulated. The reference profiler always runs.
+	OnlyWorkloads string
+	OnlyProfilers string
+}
+
+// Run simulates all cells and returns their results.
+func (r *Runner) Run() (*results.Results, error) {
+	res := results.New()
+	return res, r.RunInto(res)
+}
+
+// RunInto simulates all cells and adds the profiles to res after each trial,
+// which allows callers to access partial results by locking res. The results
+// don't depend on Parallelism. If res is nil, results are only passed to
+// OnResult, e.g. to store them elsewhere.
+func (r *Runner) RunInto(res *results.Results) error {
+	trialSeeds, err := r.TrialSeedList()
+	if err != nil {
+		return err
+	}
+	for trial, seed := range trialSeeds {
+		r.log(1, "trial", "trial", trial, "seed", seed)
+	}
+	trials := make([]*trialPlan, len(trialSeeds))
+	for trial, seed := range trialSeeds {
+		trials[trial] = r.plan(trial, seed)
+	}
+
+	// Groups are simulated concurrently by a pool of workers while the
+	// results are added in the order of the trials.
+	var (
+		jobs    = make(chan *part)
+		stop    = make(chan struct{})
+		workers sync.WaitGroup
+	)
+	defer workers.Wait()
+	defer close(stop)
+	go func() {
+		defer close(jobs)
+		for _, t := range trials {
+			for _, g := range t.groups {
+				for _, pt := range g.parts {
+					select {
+					case jobs <- pt:
+					case <-stop:
+						return
+					}
+				}
+			}
+		}
+	}()
+	for i := 0; i < r.parallelism(); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for pt := range jobs {
+				pt.err = r.simulate(pt)
+				// The worker simulating the last part of a group
+				// finishes it.
+				if g := pt.group; atomic.AddInt32(&g.pending, -1) == 0 {
+					g.err = r.finish(g)
+					g.done.Done()
+				}
+			}
+		}()
+	}
+
+	for _, t := range trials {
+		for _, g := range t.groups {
+			g.done.Wait()
+			if g.err != nil {
+				return g.err
+			}
+		}
+		if r.MergeTrials {
+			continue
+		}
+		if err := r.add(res, t.cells); err != nil {
+			return err
+		}
+	}
+	if r.MergeTrials {
+		cells, err := mergeTrials(trials)
+		if err != nil {
+			return err
+		}
+		return r.add(res, cells)
+	}
+	return nil
+}
+
+func (r *Runner) add(res *results.Results, cells []*cell) error {
+	for _, c := range cells {
+		if res != nil {
+			res.Add(c.key, c.profile)
+		}
+		if r.OnResult == nil {
+			continue
+		} else if err := r.OnResult(results.Result{Key: c.key, Profile: c.profile}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeTrials merges the cells of all trials into those of the first one,
+// which are planned in the same order.
+func mergeTrials(trials []*trialPlan) ([]*cell, error) {
+	cells := trials[0].cells
+	for i, c := range cells {
+		m, isMerger := c.profiler.(profiler.Merger)
+		// The peaks of the trials add up like the rest of their profiles.
+		peak := c.profile.Peak()
+		for _, t := range trials[1:] {
+			other := t.cells[i]
+			c.key.Ops += other.key.Ops
+			if isMerger {
+				if err := m.Merge(other.profiler); err != nil {
+					return nil, err
+				}
+				peak.Merge(other.profile.Peak())
+			} else {
+				c.profile.Merge(other.profile)
+			}
+		}
+		if isMerger {
+			c.profile = m.Profile()
+			c.profile.SetPeak(peak)
+		}
+	}
+	return cells, nil
+}
+
+func (r *Runner) parallelism() int {
+	if r.Parallelism > 0 {
+		return r.Parallelism
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// TrialSeedList validates the configuration and returns the seeds of all
+// trials.
+func (r *Runner) TrialSeedList() ([]int64, error) {
+	for _, name := range r.Workloads {
+		if _, ok := workload.Lookup(name); !ok {
+			return nil, fmt.Errorf("unknown workload: %q", name)
+		} else if name == "stdin" && r.Stream == nil {
+			return nil, errors.New("stdin workload requires a stream")
+		}
+	}
+	for _, name := range r.Profilers {
+		if _, ok := profiler.Lookup(name); !ok {
+			return nil, fmt.Errorf("unknown profiler: %q", name)
+		}
+	}
+	if _, err := rng.New(r.RNG, 0); err != nil {
+		return nil, err
+	}
+	if r.Init != "" {
+		if _, err := profiler.ParseSampleInit(string(r.Init)); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range r.Middleware {
+		name, arg, _ := strings.Cut(m, "=")
+		spec, ok := profiler.LookupMiddleware(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown middleware: %q", name)
+		} else if _, err := spec.New(arg, profiler.Config{Rand: rand.New(rand.NewSource(0))}); err != nil {
+			return nil, err
+		}
+	}
+	for _, g := range []string{r.OnlyWorkloads, r.OnlyProfilers} {
+		if _, err := path.Match(g, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	trialSeeds := r.TrialSeeds
+	if len(trialSeeds) == 0 {
+		for i := 0; i < r.Trials; i++ {
+			trialSeeds = append(trialSeeds, DeriveSeed(r.Seed, fmt.Sprintf("trial/%d", i)))
+		}
+	}
+	return trialSeeds, nil
+}
+
+func (r *Runner) log(level int, msg string, kv ...interface{}) {
+	if r.Log != nil {
+		r.Log.Log(level, msg, kv...)
+	}
+}
+
+func (r *Runner) formulas() []profiler.ScaleFormula {
+	if len(r.Formulas) == 0 {
+		return []profiler.ScaleFormula{profiler.ScaleHT}
+	}
+	return r.Formulas
+}
+
+// cell is the simulation of a profiler against a workload.
+type cell struct {
+	key      results.Key
+	profiler profiler.Profiler
+	profile  profiler.Profile
+	cacheKey CacheKey
+	noCache  bool
+	// newShard returns the profiler simulating the given part of a group
+	// split into shards. Part 0 is simulated by profiler.
+	newShard func(shard int) profiler.Profiler
+	shards   []profiler.Profiler
+	// peak is the profile at the peak of the live heap of workloads that
+	// free objects, see profiler.TrackPeak, and atPeak reports whether the
+	// live heap at the end is at least the one of peak.
+	peak   profiler.Profile
+	atPeak func() bool
+}
+
+// workloadFactory creates identical instances of a workload.
+type workloadFactory struct {
+	Spec workload.Spec
+	New  func() workload.Workload
+	// NewShard returns an instance for the given part of a split run, whose
+	// random stream is independent from those of the other parts. Part 0 is
+	// the same as New.
+	NewShard func(shard int) workload.Workload
+}
+
+// trialPlan holds the cells of a trial and the groups simulating them.
+type trialPlan struct {
+	cells  []*cell
+	groups []*group
+}
+
+// group is a set of cells that simulate the same workload for the same number
+// of ops and therefore share a single event stream.
+type group struct {
+	workload workloadFactory
+	ops      int64
+	trial    int
+	cells    []*cell
+	parts    []*part
+
+	// pending counts the parts that haven't been simulated yet.
+	pending int32
+	done    sync.WaitGroup
+	err     error
+}
+
+// part simulates ops start to end of a group. Unless the group is split into
+// shards, its only part simulates all ops.
+type part struct {
+	group      *group
+	shard      int
+	start, end int64
+	err        error
+}
+
+// plan returns the cells of all profilers against all workloads for all rates
+// of the given trial. Cached cells already hold their profile, the others are
+// grouped by workload and ops so that each workload generates its events only
+// once and they are fed to all profilers and rates simultaneously.
+func (r *Runner) plan(trial int, seed int64) *trialPlan {
+	newSource := func(name string, shard int) rand.Source {
+		componentSeed := DeriveSeed(seed, name)
+		if shard == 0 {
+			r.log(2, "component seed", "trial", trial, "component", name, "seed", componentSeed)
+		}
+		src, _ := rng.NewStream(r.RNG, componentSeed, shard)
+		return src
+	}
+	newRand := func(name string, shard int) *rand.Rand { return rand.New(newSource(name, shard)) }
+	newOne := func(spec profiler.Spec, rate int, formula profiler.ScaleFormula, inUse bool, shard int) profiler.Profiler {
+		config := profiler.Config{Formula: formula, Rate: rate, Rand: newRand("profiler/"+spec.Name, shard), InUse: inUse, Init: r.Init, Warmup: r.Warmup, SizeHistograms: r.SizeHistograms}
+		if w, ok := newSource("profiler/"+spec.Name+"/exp", shard).(*rng.Wyrand); ok {
+			config.ExpFill = w.ExpFloat64s
+		}
+		p := spec.New(config)
+		if spec.Name == Reference || spec.Name == Requested {
+			return p
+		}
+		for _, m := range r.Middleware {
+			name, arg, _ := strings.Cut(m, "=")
+			mspec, _ := profiler.LookupMiddleware(name)
+			mw, _ := mspec.New(arg, profiler.Config{Formula: formula, Rate: rate, Rand: newRand("middleware/"+spec.Name+"/"+name, shard)})
+			p = mw(p)
+		}
+		return p
+	}
+	newShard := func(spec profiler.Spec, rate int, formula profiler.ScaleFormula, inUse bool, shard int) profiler.Profiler {
+		if !r.PerP || !strings.HasSuffix(spec.Name, profiler.PerPSuffix) {
+			return r.reportRequested(spec.Name, newOne(spec, rate, formula, inUse, shard))
+		}
+		// The profilers of the Ps get independent random streams like
+		// shards. A PerP isn't a Merger, so it is never sharded itself.
+		return r.reportRequested(spec.Name, profiler.NewPerP(func(proc int) profiler.Profiler { return newOne(spec, rate, formula, inUse, proc) }))
+	}
+	newProfiler := func(spec profiler.Spec, rate int, formula profiler.ScaleFormula, inUse bool) profiler.Profiler {
+		return newShard(spec, rate, formula, inUse, 0)
+	}
+
+	var (
+		t      = &trialPlan{}
+		byName = map[string]*group{}
+	)
+	for _, rate := range r.Rates {
+		workloads := r.workloads(rate, newRand)
+		opsLists := r.opsLists(rate, workloads, newProfiler)
+		for _, spec := range r.profilers() {
+			if ok, _ := path.Match(r.OnlyProfilers, spec.Name); r.OnlyProfilers != "" && !ok && spec.Name != Reference {
+				continue
+			}
+			for _, wf := range workloads {
+				name := wf.New().Name()
+				inUse := frees(wf.New())
+				for _, ops := range opsLists[name] {
+					for _, formula := range r.formulas() {
+						c := &cell{
+							key: results.Key{Workload: name, Profiler: spec.Name, Rate: rate, Ops: ops, Trial: trial, Seed: seed, Formula: formula},
+							cacheKey: CacheKey{
+								Profiler:        spec.Name,
+								ProfilerVersion: spec.Version,
+								Workload:        name,
+								WorkloadVersion: wf.Spec.Version,
+								Rate:            rate,
+								Ops:             ops,
+								Formula:         formula,
+								Seed:            seed,
+							},
+							noCache: spec.NoCache,
+						}
+						if spec.Name != Reference {
+							c.cacheKey.Middleware = r.Middleware
+						}
+						if r.RNG != "go" {
+							c.cacheKey.RNG = "wyrand"
+						}
+						if r.Shards > 1 {
+							c.cacheKey.Shards = r.Shards
+						}
+						if r.GCEvery > 0 {
+							c.cacheKey.GCEvery = r.GCEvery
+						}
+						// Only cells seeing heap sizes depend on them, see
+						// allocator.
+						heapSizes := spec.Name != Requested && !(r.ReportRequested && spec.Name == Reference)
+						if r.SizeClasses && heapSizes {
+							c.cacheKey.SizeClasses = true
+						}
+						if r.Overhead > 0 && heapSizes {
+							c.cacheKey.Overhead = r.Overhead
+						}
+						if r.ReportRequested && spec.Name != Reference {
+							c.cacheKey.ReportRequested = true
+						}
+						if r.Tiny && spec.Name != Reference && spec.Name != Requested {
+							c.cacheKey.Tiny = true
+						}
+						c.cacheKey.SizeHistograms = r.SizeHistograms
+						if spec.Name != Reference && spec.Name != Requested {
+							if r.Init != "" && r.Init != profiler.InitZero {
+								c.cacheKey.Init = string(r.Init)
+							} else if r.Warmup {
+								c.cacheKey.Warmup = true
+							}
+						}
+						if d, ok := wf.New().(interface{ Digest() string }); ok {
+							c.cacheKey.Input = d.Digest()
+						}
+						t.cells = append(t.cells, c)
+
+						var ok bool
+						if !c.noCache && !r.MergeTrials {
+							c.profile, ok = r.Cache.Get(c.cacheKey)
+						}
+						r.log(2, "cache lookup", "profiler", spec.Name, "workload", name, "rate", rate, "ops", ops, "trial", trial, "formula", formula, "hit", ok)
+						if ok {
+							continue
+						}
+						spec, rate, formula := spec, rate, formula
+						c.newShard = func(shard int) profiler.Profiler { return newShard(spec, rate, formula, inUse, shard) }
+						c.profiler = c.newShard(0)
+						groupName := fmt.Sprintf("%s/%d", name, ops)
+						g := byName[groupName]
+						if g == nil {
+							g = &group{workload: wf, ops: ops, trial: trial}
+							g.done.Add(1)
+							byName[groupName] = g
+							t.groups = append(t.groups, g)
+						}
+						g.cells = append(g.cells, c)
+					}
+				}
+			}
+		}
+	}
+	for _, g := range t.groups {
+		r.split(g)
+	}
+	return t
+}
+
+// split divides g into parts, which are shards if possible.
+func (r *Runner) split(g *group) {
+	shards := r.Shards
+	// Frees may refer to objects allocated by a previous part.
+	if _, ok := g.workload.New().(workload.Ranger); !ok || int64(shards) > g.ops || frees(g.workload.New()) {
+		shards = 1
+	}
+	for _, c := range g.cells {
+		if _, ok := c.profiler.(profiler.Merger); !ok {
+			shards = 1
+		}
+	}
+	if shards < 1 {
+		shards = 1
+	}
+	for k := 0; k < shards; k++ {
+		g.parts = append(g.parts, &part{group: g, shard: k, start: g.ops * int64(k) / int64(shards), end: g.ops * int64(k+1) / int64(shards)})
+		if k == 0 {
+			continue
+		}
+		for _, c := range g.cells {
+			p := c.newShard(k)
+			p.(profiler.Merger).Resume()
+			c.shards = append(c.shards, p)
+		}
+	}
+	g.pending = int32(len(g.parts))
+}
+
+// simulate runs the workload of a part of g once for all of its cells.
+func (r *Runner) simulate(pt *part) error {
+	var (
+		start = time.Now()
+		g     = pt.group
+		multi = make(profiler.Multi, len(g.cells))
+	)
+	w := g.workload.NewShard(pt.shard)
+	for i, c := range g.cells {
+		p := c.profiler
+		if pt.shard > 0 {
+			p = c.shards[pt.shard-1]
+		}
+		p = r.triggerGC(r.allocator(c.key, p, frees(w)))
+		if frees(w) {
+			// Such workloads aren't split, so c.peak belongs to this
+			// part.
+			c := c
+			p = profiler.TrackPeak(func(prof profiler.Profile) { c.peak = prof })(p)
+			c.atPeak = p.(interface{ AtPeak() bool }).AtPeak
+		}
+		multi[i] = profiler.AsIDProfiler(p)
+	}
+	if len(g.parts) > 1 {
+		w.(workload.Ranger).WorkRange(pt.start, pt.end, multi)
+		r.log(1, "shard done", "workload", w.Name(), "ops", g.ops, "trial", g.trial, "shard", pt.shard, "start", pt.start, "end", pt.end, "duration", time.Since(start))
+		return nil
+	}
+	w.Work(g.ops, multi)
+	r.log(1, "workload done", "workload", w.Name(), "ops", g.ops, "trial", g.trial, "profilers", len(multi), "duration", time.Since(start))
+	return nil
+}
+
+// finish merges the samples of the parts of g and stores the profiles of its
+// cells.
+func (r *Runner) finish(g *group) error {
+	for _, pt := range g.parts {
+		if pt.err != nil {
+			return pt.err
+		}
+	}
+	for _, c := range g.cells {
+		for _, p := range c.shards {
+			if err := c.profiler.(profiler.Merger).Merge(p); err != nil {
+				return err
+			}
+		}
+		c.shards = nil
+		c.profile = c.profiler.Profile()
+		if c.peak == nil || c.atPeak() {
+			// Without frees the live heap peaks at the end, and with
+			// them it may as well.
+			c.peak = c.profile
+		}
+		c.profile.SetPeak(c.peak)
+		c.peak, c.atPeak = nil, nil
+		if e, ok := c.profiler.(interface{ Err() error }); ok && e.Err() != nil {
+			return e.Err()
+		}
+		kv := []interface{}{"profiler", c.key.Profiler, "workload", c.key.Workload, "rate", c.key.Rate, "ops", c.key.Ops, "trial", g.trial, "formula", c.key.Formula}
+		if sc, ok := c.profiler.(interface{ Samples() int64 }); ok {
+			kv = append(kv, "samples", sc.Samples())
+		}
+		r.log(1, "cell done", kv...)
+		if r.MergeTrials {
+			// The samples are merged with those of the other trials.
+			continue
+		}
+		// Only the profile is needed from here on, so let the samples be
+		// collected before the remaining groups of the trial are simulated.
+		c.profiler = nil
+		if c.noCache {
+			// External profilers may change without a version bump.
+		} else if err := r.Cache.Put(c.cacheKey, c.profile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// workloads returns factories for all selected workloads at the given rate.
+func (r *Runner) workloads(rate int, newRand func(string, int) *rand.Rand) []workloadFactory {
+	var bigs []int
+	bigs = append(bigs, r.Big...)
+	for _, f := range r.BigRate {
+		bigs = append(bigs, int(f*float64(rate)))
+	}
+
+	var (
+		workloads []workloadFactory
+		seen      = map[string]bool{}
+	)
+	for _, big := range bigs {
+		for _, small := range r.Small {
+			for _, name := range r.Workloads {
+				spec, _ := workload.Lookup(name)
+				config := workload.Config{Small: small, Big: big, Stream: r.Stream, Procs: r.Procs}
+				wf := workloadFactory{Spec: spec, NewShard: func(shard int) workload.Workload {
+					config := config
+					config.Rand = newRand("workload/"+spec.Name, shard)
+					return spec.New(config)
+				}}
+				wf.New = func() workload.Workload { return wf.NewShard(0) }
+				// Workloads that don't depend on all parameters, e.g.
+				// stdin, would otherwise be simulated multiple times.
+				name := wf.New().Name()
+				if ok, _ := path.Match(r.OnlyWorkloads, name); r.OnlyWorkloads != "" && !ok {
+					continue
+				} else if !seen[name] {
+					seen[name] = true
+					workloads = append(workloads, wf)
+				}
+			}
+		}
+	}
+	return workloads
+}
+
+// opsLists returns the numbers of ops to simulate for each workload at the
+// given rate.
+func (r *Runner) opsLists(rate int, workloads []workloadFactory, newProfiler func(profiler.Spec, int, profiler.ScaleFormula, bool) profiler.Profiler) map[string][]int64 {
+	// All profilers simulate the same number of ops for a workload so their
+	// results can be compared. With a time budget this is limited by the
+	// slowest profiler.
+	opsLists := make(map[string][]int64)
+	for _, wf := range workloads {
+		name := wf.New().Name()
+		if r.Duration > 0 {
+			var ops int64
+			for _, spec := range r.profilers() {
+				spec := spec
+				n := r.calibrate(func() profiler.Profiler { return newProfiler(spec, rate, r.formulas()[0], frees(wf.New())) }, wf.New)
+				if ops == 0 || n < ops {
+					ops = n
+				}
+			}
+			r.log(1, "calibrated", "workload", name, "rate", rate, "ops", ops)
+			opsLists[name] = []int64{ops}
+			continue
+		}
+		var (
+			exps []int
+			ok   bool
+		)
+		if r.WorkloadExp != nil {
+			exps, ok = r.WorkloadExp(name)
+		}
+		if !ok {
+			if l, isFinite := wf.New().(interface{ Len() int64 }); isFinite {
+				opsLists[name] = []int64{l.Len()}
+				continue
+			}
+			exps = r.Exp
+		}
+		for _, exp := range exps {
+			opsLists[name] = append(opsLists[name], int64(math.Pow10(exp)))
+		}
+	}
+	return opsLists
+}
+
+// frees reports whether w may free objects, in which case the profilers
+// track which of their samples are in use.
+func frees(w workload.Workload) bool {
+	f, ok := w.(workload.Freer)
+	return ok && f.Frees()
+}
+
+// allocator returns p seeing allocations like the profiler of key does: with
+// the tiny allocator, the overhead and rounded to size classes if enabled.
+// frees tells whether the workload frees objects.
+func (r *Runner) allocator(key results.Key, p profiler.Profiler, frees bool) profiler.Profiler {
+	if key.Profiler == Requested || r.ReportRequested && key.Profiler == Reference {
+		return p
+	}
+	if !r.ReportRequested {
+		// Otherwise profiler.ReportRequested passes the heap sizes.
+		if r.SizeClasses {
+			p = profiler.RoundSizes()(p)
+		}
+		if r.Overhead > 0 {
+			p = profiler.AddOverhead(r.Overhead)(p)
+		}
+	}
+	if r.Tiny && key.Profiler != Reference {
+		p = profiler.TinyAlloc(frees)(p)
+	}
+	return p
+}
+
+// heapSize returns the heap bytes of an allocation of size bytes.
+func (r *Runner) heapSize(size int) int {
+	size += r.Overhead
+	if r.SizeClasses {
+		size = profiler.SizeClass(size)
+	}
+	return size
+}
+
+// reportRequested returns p reporting requested bytes if the profiler name
+// does so.
+func (r *Runner) reportRequested(name string, p profiler.Profiler) profiler.Profiler {
+	if !r.ReportRequested || name == Reference || name == Requested {
+		return p
+	}
+	return profiler.ReportRequested(r.heapSize)(p)
+}
+
+// triggerGC returns p with simulated GC cycles if GCEvery is set.
+func (r *Runner) triggerGC(p profiler.Profiler) profiler.Profiler {
+	if r.GCEvery <= 0 {
+		return p
+	}
+	return profiler.TriggerGC(r.GCEvery)(p)
+}
+
+// profilers returns the selected profilers, starting with the reference and,
+// with SizeClasses or Overhead, Requested. With PerP, each other profiler is followed by
+// its copy with a state per P.
+func (r *Runner) profilers() []profiler.Spec {
+	spec, _ := profiler.Lookup(Reference)
+	specs := []profiler.Spec{spec}
+	if (r.SizeClasses || r.Overhead > 0) && !r.ReportRequested {
+		spec.Name = Requested
+		specs = append(specs, spec)
+	}
+	for _, name := range r.Profilers {
+		if spec, ok := profiler.Lookup(name); ok && name != Reference {
+			specs = append(specs, spec)
+			if r.PerP {
+				spec.Name += profiler.PerPSuffix
+				specs = append(specs, spec)
+			}
+		}
+	}
+	return specs
+}
+
+// calibrate returns the number of ops the profiler can simulate for the
+// workload within r.Duration by timing increasingly larger runs of throwaway
+// instances.
+func (r *Runner) calibrate(newProfiler func() profiler.Profiler, newWorkload func() workload.Workload) int64 {
+	target := r.Duration / 100
+	for ops := int64(1000); ; ops *= 10 {
+		start := time.Now()
+		newWorkload().Work(ops, newProfiler())
+		if d := time.Since(start); d >= target {
+			return int64(float64(ops) * float64(r.Duration) / float64(d))
+		}
+	}
+}
+
+// DeriveSeed returns the seed for the random number generator of the named
+// component, e.g. a trial or a profiler within a trial. Hashing the inputs
+// keeps each component's random stream stable when other components are added
+// or removed.
+func DeriveSeed(seed int64, name string) int64 {
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, seed)
+	io.WriteString(h, name)
+	// fnv barely mixes the last bytes, so finalize with splitmix64 to avoid
+	// similar seeds for similar names.
+	z := h.Sum64()
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
@@ -0,0 +1,33 @@
+package engine_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/felixge/alloc-prof-sim/engine"
+)
+
+func ExampleNew() {
+	res, err := engine.New(
+		engine.WithProfilers("go"),
+		engine.WithWorkloads("interleave"),
+		engine.WithRates(512*1024),
+		engine.WithSizes([]int{16}, []int{128}),
+		engine.WithExp(6),
+		engine.WithTrials(2),
+	).Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The cells are simulated concurrently, but the results are added in
+	// the order of their trials, rates, profilers, workloads, ops and
+	// formulas, with the reference profiler first.
+	for _, r := range res.List {
+		fmt.Println(r.Workload, r.Trial, r.Profiler, r.Profile["small"].Bytes)
+	}
+	// Output:
+	// interleave-16-128 0 perfect 16000000
+	// interleave-16-128 0 go 17301768
+	// interleave-16-128 1 perfect 16000000
+	// interleave-16-128 1 go 14155992
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+	"github.com/felixge/alloc-prof-sim/workload"
+)
+
+// Option configures a Runner created by New.
+type Option func(*Runner)
+
+// New returns a runner with the same defaults as the command line tool,
+// modified by opts. It runs all registered profilers against the built-in
+// synthetic workloads at a rate of 100 KiB and 10^8 ops per cell.
+func New(opts ...Option) *Runner {
+	r := &Runner{
+		Workloads: []string{"sequential", "interleave", "interleave-rand"},
+		Formulas:  []profiler.ScaleFormula{profiler.ScaleHT},
+		Rates:     []int{100 * 1024},
+		Small:     []int{16},
+		Big:       []int{128},
+		BigRate:   []float64{2},
+		Exp:       []int{8},
+		Trials:    1,
+	}
+	for _, spec := range profiler.Specs() {
+		r.Profilers = append(r.Profilers, spec.Name)
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithProfilers selects the registered profilers to run. The reference
+// profiler always runs.
+func WithProfilers(names ...string) Option {
+	return func(r *Runner) { r.Profilers = names }
+}
+
+// WithWorkloads selects the registered workloads to run.
+func WithWorkloads(names ...string) Option {
+	return func(r *Runner) { r.Workloads = names }
+}
+
+// WithFormulas sets the formulas for scaling sampled values.
+func WithFormulas(formulas ...profiler.ScaleFormula) Option {
+	return func(r *Runner) { r.Formulas = formulas }
+}
+
+// WithRates sets the sampling rates in bytes.
+func WithRates(rates ...int) Option {
+	return func(r *Runner) { r.Rates = rates }
+}
+
+// WithSizes sets the small and big allocation sizes in bytes. It disables the
+// big allocation sizes relative to the rate unless WithBigRate is applied
+// afterwards.
+func WithSizes(small, big []int) Option {
+	return func(r *Runner) { r.Small, r.Big, r.BigRate = small, big, nil }
+}
+
+// WithBigRate sets additional big allocation sizes as multiples of the rate.
+func WithBigRate(multiples ...float64) Option {
+	return func(r *Runner) { r.BigRate = multiples }
+}
+
+// WithExp sets the number of ops per cell as powers of ten.
+func WithExp(exps ...int) Option {
+	return func(r *Runner) { r.Exp = exps }
+}
+
+// WithDuration runs each cell for about d instead of a fixed number of ops.
+func WithDuration(d time.Duration) Option {
+	return func(r *Runner) { r.Duration = d }
+}
+
+// WithSeed sets the seed that trial seeds are derived from.
+func WithSeed(seed int64) Option {
+	return func(r *Runner) { r.Seed = seed }
+}
+
+// WithTrials sets the number of trials.
+func WithTrials(n int) Option {
+	return func(r *Runner) { r.Trials = n }
+}
+
+// WithTrialSeeds runs one trial per seed instead of deriving them.
+func WithTrialSeeds(seeds ...int64) Option {
+	return func(r *Runner) { r.TrialSeeds = seeds }
+}
+
+// WithMiddleware wraps every profiler except the reference with the
+// registered middleware given as NAME[=ARG], in order.
+func WithMiddleware(specs ...string) Option {
+	return func(r *Runner) { r.Middleware = specs }
+}
+
+// WithParallelism sets the number of workloads simulated concurrently.
+func WithParallelism(n int) Option {
+	return func(r *Runner) { r.Parallelism = n }
+}
+
+// WithShards splits the ops of each workload into n parts that are simulated
+// concurrently, see Runner.Shards.
+func WithShards(n int) Option {
+	return func(r *Runner) { r.Shards = n }
+}
+
+// WithMergeTrials reports a single result per cell for all trials, see
+// Runner.MergeTrials.
+func WithMergeTrials() Option {
+	return func(r *Runner) { r.MergeTrials = true }
+}
+
+// WithRNG sets the name of the random number source, see rng.New.
+func WithRNG(source string) Option {
+	return func(r *Runner) { r.RNG = source }
+}
+
+// WithStream sets the input of the stdin workload.
+func WithStream(stream *workload.AllocStream) Option {
+	return func(r *Runner) { r.Stream = stream }
+}
+
+// WithCache caches simulated profiles in dir.
+func WithCache(dir string) Option {
+	return func(r *Runner) { r.Cache = Cache{Dir: dir} }
+}
+
+// WithLogger sets the logger for progress messages.
+func WithLogger(l Logger) Option {
+	return func(r *Runner) { r.Log = l }
+}
+
+// WithSizeClasses rounds allocation sizes to the size classes of the Go
+// runtime, see Runner.SizeClasses.
+func WithSizeClasses() Option {
+	return func(r *Runner) { r.SizeClasses = true }
+}
+
+// WithOverhead adds bytes of allocator metadata to each allocation, see
+// Runner.Overhead.
+func WithOverhead(bytes int) Option {
+	return func(r *Runner) { r.Overhead = bytes }
+}
+
+// WithReportRequested makes profilers report requested bytes while they
+// sample on heap bytes, see Runner.ReportRequested.
+func WithReportRequested() Option {
+	return func(r *Runner) { r.ReportRequested = true }
+}
+
+// WithTiny combines small allocations like the tiny allocator of the Go
+// runtime, see Runner.Tiny.
+func WithTiny() Option {
+	return func(r *Runner) { r.Tiny = true }
+}
+
+// WithInit sets the distance to the first sample of sampling profilers, see
+// Runner.Init.
+func WithInit(init profiler.SampleInit) Option {
+	return func(r *Runner) { r.Init = init }
+}
+
+// WithWarmup makes sampling profilers count a certain first sample as is, see
+// Runner.Warmup.
+func WithWarmup() Option {
+	return func(r *Runner) { r.Warmup = true }
+}
+
+// WithSizeHistograms makes profilers report a histogram of the sizes of each
+// stack, see Runner.SizeHistograms.
+func WithSizeHistograms() Option {
+	return func(r *Runner) { r.SizeHistograms = true }
+}
+
+// WithPerP additionally simulates each profiler with a state per P, see
+// Runner.PerP.
+func WithPerP() Option {
+	return func(r *Runner) { r.PerP = true }
+}
+
+// WithProcs sets the number of Ps of workloads running goroutines in
+// parallel.
+func WithProcs(n int) Option {
+	return func(r *Runner) { r.Procs = n }
+}
+
+// WithGCEvery simulates the end of a GC cycle each time bytes bytes have been
+// allocated, see Runner.GCEvery.
+func WithGCEvery(bytes int64) Option {
+	return func(r *Runner) { r.GCEvery = bytes }
+}
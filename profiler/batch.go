@@ -0,0 +1,147 @@
+package profiler
+
+import (
+	"math"
+
+	"github.com/felixge/alloc-prof-sim/rng"
+)
+
+// BatchProfiler is implemented by profilers that can account for a run of
+// identical allocations faster than one at a time.
+type BatchProfiler interface {
+	Profiler
+	MallocN(size int, count int64, id StackID)
+}
+
+// MallocN reports count allocations of size at the interned stack id to p,
+// falling back to individual allocations if p doesn't implement
+// BatchProfiler.
+func MallocN(p Profiler, size int, count int64, id StackID) {
+	if bp, ok := p.(BatchProfiler); ok {
+		bp.MallocN(size, count, id)
+		return
+	}
+	for i := int64(0); i < count; i++ {
+		MallocID(p, size, id)
+	}
+}
+
+func (m Multi) MallocN(size int, count int64, id StackID) {
+	for _, p := range m {
+		MallocN(p, size, count, id)
+	}
+}
+
+func (p *Perfect) MallocN(size int, count int64, id StackID) {
+	p.prof.add(id, count, count*int64(size))
+	p.sizes.add(id, size, count)
+}
+
+// skip returns how many of count allocations of size don't reach the next
+// sample, i.e. decrement next without being sampled.
+func skip(size int, count int64, next int) int64 {
+	if size >= next {
+		return 0
+	} else if size == 0 {
+		return count
+	}
+	if n := int64((next - 1) / size); n < count {
+		return n
+	}
+	return count
+}
+
+func (p *DotNet) MallocN(size int, count int64, id StackID) {
+	if p.Naive || p.Rate <= 0 {
+		p.mallocNaive(size, count, id)
+		return
+	}
+	// The thresholds are Rate bytes apart, starting at nextSample. An
+	// allocation is sampled if a threshold falls within it, which is the
+	// case for each threshold unless allocations are larger than Rate.
+	bytes, next := count*int64(size), int64(p.nextSample)
+	if count == 0 {
+		return
+	} else if bytes < next {
+		p.nextSample -= int(bytes)
+		p.live.add(id, size, count, 0)
+		return
+	}
+	thresholds := (bytes-next)/int64(p.Rate) + 1
+	samples := thresholds
+	if samples > count {
+		samples = count
+	}
+	samples, count = p.warmUp(id, size, samples, count)
+	p.prof.add(id, samples, samples*int64(size))
+	p.live.add(id, size, count, samples)
+	p.sizes.add(id, size, samples)
+	if p.PerSample {
+		p.est.add(id, size, samples, p.factor(size))
+	}
+	p.nextSample = int(next + thresholds*int64(p.Rate) - bytes)
+}
+
+func (p *DotNet) mallocNaive(size int, count int64, id StackID) {
+	n := skip(size, count, p.nextSample)
+	p.nextSample -= int(n) * size
+	p.live.add(id, size, n, 0)
+	if count -= n; count == 0 {
+		return
+	}
+	// Every sample resets the sampling distance to Rate, so the allocations
+	// after the first sample repeat with a period of one sample each.
+	period := skip(size, count, p.Rate) + 1
+	samples := 1 + (count-1)/period
+	p.nextSample = p.Rate - int((count-1)%period)*size
+	samples, count = p.warmUp(id, size, samples, count)
+	p.prof.add(id, samples, samples*int64(size))
+	p.live.add(id, size, count, samples)
+	p.sizes.add(id, size, samples)
+	if p.PerSample {
+		p.est.add(id, size, samples, p.factor(size))
+	}
+}
+
+// MallocN samples the allocations up to the first sample one by one and then
+// draws the number of remaining samples from the binomial distribution. The
+// sampling distances are exponentially distributed, so each later allocation
+// is sampled independently with probability 1 - e^(-size/rate), and the
+// distance drawn for the first sample remains valid after the run, with or
+// without Remainder. Without Remainder, MallocID samples the allocation right
+// after a sample as if it were a byte larger, see Go, which the binomial
+// draw doesn't model. This gives about 1/rate fewer samples than allocating
+// one at a time, which only shows at tiny rates.
+func (p *Go) MallocN(size int, count int64, id StackID) {
+	if p.Uniform {
+		// Its distances aren't memoryless.
+		for ; count > 0; count-- {
+			p.MallocID(size, id)
+		}
+		return
+	}
+	var n int64
+	if p.Rate != 1 {
+		n = skip(size, count, p.nextSample)
+	}
+	p.nextSample -= int(n) * size
+	p.live.add(id, size, n, 0)
+	if count -= n; count == 0 {
+		return
+	}
+	p.MallocID(size, id)
+	prob := 1.0
+	if p.Rate > 1 {
+		prob = -math.Expm1(-float64(size) / float64(p.Rate))
+	}
+	samples := rng.Binomial(p.Rand, count-1, prob)
+	p.prof.add(id, samples, samples*int64(size))
+	p.live.add(id, size, count-1, samples)
+	p.sizes.add(id, size, samples)
+	if p.Buckets {
+		p.sized.add(id, size, samples)
+	}
+	if p.PerSample {
+		p.est.add(id, size, samples, p.factor(size))
+	}
+}
@@ -0,0 +1,155 @@
+package profiler
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/felixge/alloc-prof-sim/stats"
+)
+
+// batchRuns are runs of allocations of the same size, given as size and
+// count, that cross sampling thresholds at different offsets.
+var batchRuns = [][2]int{{30, 10}, {30, 1}, {30, 1}, {7, 50}, {250, 3}, {30, 6}, {1, 400}, {99, 2}}
+
+// TestDotNetMallocN checks that allocating runs with MallocN samples the same
+// allocations as allocating them one at a time, with and without warmup.
+func TestDotNetMallocN(t *testing.T) {
+	id := Intern("batch")
+	for _, naive := range []bool{false, true} {
+		for _, warmup := range []bool{false, true} {
+			newDotNet := func() *DotNet { return NewDotNet(100, WithNaive(naive), WithWarmup(warmup)) }
+			batch, single := newDotNet(), newDotNet()
+			for i, run := range batchRuns {
+				size, count := run[0], run[1]
+				batch.MallocN(size, int64(count), id)
+				for j := 0; j < count; j++ {
+					single.MallocID(size, id)
+				}
+				if batch.nextSample != single.nextSample || batch.Samples() != single.Samples() {
+					t.Fatalf("naive=%v warmup=%v run %d: MallocN gives next sample %d and %d samples, MallocID %d and %d", naive, warmup, i, batch.nextSample, batch.Samples(), single.nextSample, single.Samples())
+				}
+			}
+			if got, want := batch.Profile(), single.Profile(); !reflect.DeepEqual(got, want) {
+				t.Errorf("naive=%v warmup=%v: MallocN gives %v, MallocID %v", naive, warmup, got, want)
+			}
+		}
+	}
+}
+
+// TestMallocN checks for every registered profiler that allocating runs with
+// MallocN gives the same profile as allocating them one at a time with the
+// same seed. Go only samples the first allocation of a run like MallocID and
+// draws the rest, so its variants are compared in distribution instead, see
+// TestGoMallocN.
+func TestMallocN(t *testing.T) {
+	id := Intern("batch")
+	for _, spec := range Specs() {
+		if _, ok := spec.New(Config{Rate: 100, Rand: rand.New(rand.NewSource(1))}).(*Go); ok && spec.Name != "go-uniform" {
+			continue
+		}
+		for _, warmup := range []bool{false, true} {
+			newProfiler := func() Profiler {
+				return spec.New(Config{Formula: ScaleHT, Rate: 100, Rand: rand.New(rand.NewSource(1)), Init: InitExp, Warmup: warmup, SizeHistograms: true})
+			}
+			batch, single := newProfiler(), newProfiler()
+			for i := 0; i < 50; i++ {
+				for _, run := range batchRuns {
+					size, count := run[0], run[1]
+					MallocN(batch, size, int64(count), id)
+					for j := 0; j < count; j++ {
+						MallocID(single, size, id)
+					}
+				}
+			}
+			if got, want := batch.Profile(), single.Profile(); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s warmup=%v: MallocN gives %v, MallocID %v", spec.Name, warmup, got, want)
+			}
+		}
+	}
+}
+
+// TestGoMallocN checks for the Go variants that draw the samples of a run
+// that the mean number of samples with MallocN lies within a few standard
+// errors of that with MallocID. Its runs are long, so that most samples are
+// drawn, and its rate is large enough that MallocN missing the truncation
+// after a sample doesn't show.
+func TestGoMallocN(t *testing.T) {
+	const (
+		trials = 500
+		rate   = 4096
+	)
+	id := Intern("batch")
+	runs := [][2]int{{1000, 2000}, {300, 10000}, {8000, 250}, {16, 50000}}
+	for _, name := range fuzzProfilers {
+		spec, _ := Lookup(name)
+		var batch, single []float64
+		for trial := int64(0); trial < trials; trial++ {
+			b := spec.New(Config{Formula: ScaleHT, Rate: rate, Rand: rand.New(rand.NewSource(trial))})
+			s := spec.New(Config{Formula: ScaleHT, Rate: rate, Rand: rand.New(rand.NewSource(trial))})
+			for _, run := range runs {
+				size, count := run[0], run[1]
+				MallocN(b, size, int64(count), id)
+				for j := 0; j < count; j++ {
+					MallocID(s, size, id)
+				}
+			}
+			batch = append(batch, float64(b.(*Go).Samples()))
+			single = append(single, float64(s.(*Go).Samples()))
+		}
+		diff := stats.Mean(batch) - stats.Mean(single)
+		se := math.Sqrt((math.Pow(stats.StdDev(batch), 2) + math.Pow(stats.StdDev(single), 2)) / trials)
+		if math.Abs(diff) > 5*se {
+			t.Errorf("%s: MallocN takes %.1f samples on average, MallocID %.1f, standard error %.2f", name, stats.Mean(batch), stats.Mean(single), se)
+		}
+	}
+}
+
+// TestExpFill checks that drawing the sampling distances of the Go variants
+// in batches from ExpFill gives the same profile as drawing them one at a time
+// from a Rand with the same seed.
+func TestExpFill(t *testing.T) {
+	id := Intern("batch")
+	for _, name := range fuzzProfilers {
+		spec, _ := Lookup(name)
+		fill := rand.New(rand.NewSource(1))
+		batched := spec.New(Config{Formula: ScaleHT, Rate: 100, Rand: rand.New(rand.NewSource(2)), ExpFill: func(dst []float64) {
+			for i := range dst {
+				dst[i] = fill.ExpFloat64()
+			}
+		}})
+		single := spec.New(Config{Formula: ScaleHT, Rate: 100, Rand: rand.New(rand.NewSource(1))})
+		// Enough samples to refill the batch several times.
+		for i := 0; i < 50; i++ {
+			for _, run := range batchRuns {
+				for j := 0; j < run[1]; j++ {
+					MallocID(batched, run[0], id)
+					MallocID(single, run[0], id)
+				}
+			}
+		}
+		if single.(*Go).Samples() < 2*goExpBatch {
+			t.Fatalf("%s: only %d samples", name, single.(*Go).Samples())
+		}
+		if got, want := batched.Profile(), single.Profile(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: ExpFill gives %v, ExpFloat64 %v", name, got, want)
+		}
+	}
+}
+
+// BenchmarkGoMallocN allocates runs of 1000 allocations of 16 bytes, which
+// rarely take a sample at the default rate of the runtime and about 16 at a
+// rate of 1024.
+func BenchmarkGoMallocN(b *testing.B) {
+	id := Intern("batch")
+	for _, rate := range []int{512 * 1024, 1024} {
+		b.Run(strconv.Itoa(rate), func(b *testing.B) {
+			p := NewGo(rate, WithRand(rand.New(rand.NewSource(1))))
+			for i := 0; i < b.N; i++ {
+				p.MallocN(16, 1000, id)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package profiler
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/felixge/alloc-prof-sim/stats"
+)
+
+const (
+	// biasSamples is the expected number of samples per trial. It bounds the
+	// rounding error of the deterministic samplers, which may miss a sample.
+	biasSamples = 1000
+	// biasTolerance is the mean relative error in percent beyond which a
+	// profiler is biased, as for the bias command.
+	biasTolerance = 1
+	biasPairs     = 50
+)
+
+// TestUnbiased checks like the bias command that the go and dotnet profilers
+// estimate the bytes of single size allocations without bias for random
+// combinations of size and rate. A combination fails if the confidence
+// interval of the mean relative error across trials lies entirely beyond
+// biasTolerance.
+func TestUnbiased(t *testing.T) {
+	profilers := []string{"go", "dotnet"}
+	level := 1 - fuzzAlpha/float64(biasPairs*len(profilers))
+	id := Intern("alloc")
+	prop := func(r, s uint16) bool {
+		// Rates between 512 B and 1 MiB and sizes between 1 B and 4 times
+		// the rate, both log-uniformly distributed.
+		rate := int(math.Exp(math.Log(512) + float64(r)/math.MaxUint16*math.Log(2048)))
+		size := int(math.Exp(float64(s) / math.MaxUint16 * math.Log(4*float64(rate))))
+		n := int64(math.Ceil(biasSamples * float64(rate) / float64(size)))
+		want := float64(n) * float64(size)
+
+		ok := true
+		for _, name := range profilers {
+			spec, _ := Lookup(name)
+			var errs []float64
+			for trial := int64(0); trial < fuzzTrials; trial++ {
+				p := spec.New(Config{Formula: ScaleHT, Rate: rate, Rand: rand.New(rand.NewSource(trial))})
+				MallocN(p, 1, 1, FuzzWarmup)
+				MallocN(p, size, n, id)
+				errs = append(errs, stats.RelError(float64(p.Profile()[id.Stack()].Bytes), want))
+			}
+			mean, lo, hi := stats.MeanCI(errs, level)
+			if lo > biasTolerance || hi < -biasTolerance {
+				t.Errorf("%s size=%d rate=%d: mean error %.2f%%, interval [%.2f%%, %.2f%%]", name, size, rate, mean, lo, hi)
+				ok = false
+			}
+		}
+		return ok
+	}
+	if err := quick.Check(prop, &quick.Config{MaxCount: biasPairs, Rand: rand.New(rand.NewSource(1))}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,53 @@
+package profiler
+
+// WithBuckets sets whether the go profiler estimates the samples of each stack
+// and size separately, see Go.Buckets.
+func WithBuckets(buckets bool) Option {
+	return func(c *Config) { c.Buckets = buckets }
+}
+
+// sizedCounts holds the samples of a profiler by size, so that the samples of
+// each (stack, size) bucket can be estimated separately.
+type sizedCounts map[int]*sizedCount
+
+type sizedCount struct {
+	prof, freed counts
+}
+
+func (s *sizedCounts) get(size int) *sizedCount {
+	if *s == nil {
+		*s = sizedCounts{}
+	}
+	c := (*s)[size]
+	if c == nil {
+		c = &sizedCount{}
+		(*s)[size] = c
+	}
+	return c
+}
+
+func (s *sizedCounts) add(id StackID, size int, objects int64) {
+	s.get(size).prof.add(id, objects, objects*int64(size))
+}
+
+func (s *sizedCounts) free(id StackID, size int) {
+	s.get(size).freed.add(id, 1, int64(size))
+}
+
+func (s *sizedCounts) merge(o sizedCounts) {
+	for size, oc := range o {
+		c := s.get(size)
+		c.prof.merge(&oc.prof)
+		c.freed.merge(&oc.freed)
+	}
+}
+
+// estimate applies e, or formula f if e is nil, to the samples of each size
+// and adds up the estimates.
+func (s sizedCounts) estimate(e Estimator, f, ht ScaleFormula, rate int) Profile {
+	var p Profile
+	for _, c := range s {
+		p.Merge(estimate(e, f, ht, inUse(c.prof.profile(), c.freed.profile()), rate))
+	}
+	return p
+}
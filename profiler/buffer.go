@@ -0,0 +1,121 @@
+package profiler
+
+// SampleDropper is implemented by profilers that can discard a sample they
+// have just taken, e.g. because it didn't fit into a buffer. Discarding it
+// doesn't undo its effect on the sampling itself.
+type SampleDropper interface {
+	Profiler
+	DropSample(size int, id StackID)
+}
+
+// DropSample discards the last sample of an object of size at stack id taken
+// by p. It does nothing if p doesn't implement SampleDropper.
+func DropSample(p Profiler, size int, id StackID) {
+	if d, ok := p.(SampleDropper); ok {
+		d.DropSample(size, id)
+	}
+}
+
+func (p *DotNet) DropSample(size int, id StackID) {
+	if dropWarm(&p.prof, &p.warm, p.sizes, size, id) {
+		p.certain = false
+		return
+	}
+	p.prof.add(id, -1, -int64(size))
+	p.live.add(id, size, 0, -1)
+	p.sizes.add(id, size, -1)
+	if p.PerSample {
+		p.est.add(id, size, -1, p.factor(size))
+	}
+}
+
+func (p *Go) DropSample(size int, id StackID) {
+	if dropWarm(&p.prof, &p.warm, p.sizes, size, id) {
+		p.certain = false
+		return
+	}
+	p.prof.add(id, -1, -int64(size))
+	p.live.add(id, size, 0, -1)
+	p.sizes.add(id, size, -1)
+	if p.Buckets {
+		p.sized.add(id, size, -1)
+	}
+	if p.PerSample {
+		p.est.add(id, size, -1, p.factor(size))
+	}
+}
+
+// BufferSamples returns a middleware that passes the samples of the profiler
+// through a buffer of capacity samples, which is drained by one sample every
+// every allocations, and drops the samples that don't fit, like an agent
+// that can't keep up during bursts of samples. The profiler must report its
+// samples and implement SampleDropper, otherwise no samples are dropped. Runs
+// of identical allocations, see MallocN, are split at each drained sample,
+// and the samples of each part arrive at its end.
+func BufferSamples(capacity int, every int64) Middleware {
+	return func(p Profiler) Profiler {
+		return &bufferSamples{Wrapper: Wrapper{p}, capacity: int64(capacity), every: every}
+	}
+}
+
+type bufferSamples struct {
+	Wrapper
+	capacity, every int64
+	// queued is the number of samples in the buffer and clock the number
+	// of allocations since the last sample was drained.
+	queued, clock int64
+}
+
+func (p *bufferSamples) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+
+func (p *bufferSamples) MallocID(size int, id StackID) {
+	before := p.Samples()
+	MallocID(p.Profiler, size, id)
+	p.buffer(size, 1, id, p.Samples()-before)
+}
+
+func (p *bufferSamples) MallocN(size int, count int64, id StackID) {
+	for count > 0 {
+		n := count
+		if p.every > 0 && p.every-p.clock < n {
+			n = p.every - p.clock
+		}
+		before := p.Samples()
+		MallocN(p.Profiler, size, n, id)
+		p.buffer(size, n, id, p.Samples()-before)
+		count -= n
+	}
+}
+
+func (p *bufferSamples) FreeID(size int, id StackID) { FreeID(p.Profiler, size, id) }
+
+// buffer drains the buffer for count allocations and then adds the given
+// number of samples of size at id to it, dropping those that don't fit.
+func (p *bufferSamples) buffer(size int, count int64, id StackID, samples int64) {
+	if p.clock += count; p.every > 0 {
+		if p.queued -= p.clock / p.every; p.queued < 0 {
+			p.queued = 0
+		}
+		p.clock %= p.every
+	}
+	for ; samples > 0; samples-- {
+		if p.queued < p.capacity {
+			p.queued++
+		} else {
+			DropSample(p.Profiler, size, id)
+		}
+	}
+}
+
+// dropWarm discards the certain first sample of size at id from warm if it is
+// the sample being dropped, see WithWarmup, and reports whether it was. As
+// the first of all samples, it is the one being dropped if no other samples
+// remain in prof, since the samples taken with it were dropped before it.
+func dropWarm(prof, warm *counts, sizes *sizeWeights, size int, id StackID) bool {
+	if prof.objects() > 0 || warm.objects() == 0 {
+		return false
+	}
+	warm.add(id, -1, -int64(size))
+	sizes.addWeighted(id, size, -1, 1)
+	return true
+}
@@ -0,0 +1,25 @@
+package profiler
+
+import "testing"
+
+// TestDropWarmSample checks that dropping the certain first sample taken with
+// warmup removes it rather than a sample that was never taken.
+func TestDropWarmSample(t *testing.T) {
+	for _, p := range []Profiler{
+		NewGo(1<<20, WithWarmup(true)),
+		NewDotNet(1<<20, WithWarmup(true)),
+	} {
+		bp := BufferSamples(0, 1000)(p)
+		bp.Malloc(8, "a")
+		if got := bp.Profile(); len(got) != 0 {
+			t.Errorf("%s: got %v after dropping the only sample, want an empty profile", p.Name(), got)
+		}
+		// Further samples are scaled as usual and dropped as well.
+		for i := 0; i < 1000; i++ {
+			bp.Malloc(1<<12, "b")
+		}
+		if got := bp.Profile(); len(got) != 0 {
+			t.Errorf("%s: got %v after dropping all samples, want an empty profile", p.Name(), got)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package profiler
+
+// Estimator estimates the true allocations from the raw samples of a profiler.
+// It must not modify raw. Stacks missing from the estimate, or all stacks if
+// it is nil, are estimated to have no allocations.
+type Estimator func(raw Profile, params EstimatorParams) Profile
+
+// EstimatorParams holds the sampling parameters of the profiler whose samples
+// are estimated.
+type EstimatorParams struct {
+	Rate int
+}
+
+// Estimator returns an estimator scaling samples with f, where ht is the
+// formula that ScaleHT resolves to.
+func (f ScaleFormula) Estimator(ht ScaleFormula) Estimator {
+	return func(raw Profile, params EstimatorParams) Profile {
+		return f.Scale(raw, params.Rate, ht)
+	}
+}
+
+// WithEstimator sets the estimator of sampling profilers, overriding the
+// formula set by WithFormula.
+func WithEstimator(e Estimator) Option {
+	return func(c *Config) { c.Estimator = e }
+}
+
+// estimate applies e, or formula f if e is nil, to raw and records the raw
+// samples in the estimate. raw must not be used by the caller afterwards,
+// which lets f scale it in place rather than copy it.
+func estimate(e Estimator, f, ht ScaleFormula, raw Profile, rate int) Profile {
+	raw = sampled(raw)
+	if e == nil {
+		return f.scale(raw, rate, ht)
+	}
+	est := e(raw, EstimatorParams{Rate: rate})
+	if est == nil {
+		est = Profile{}
+	}
+	for st, v := range raw {
+		a := est[st]
+		a.SampledObjects, a.SampledBytes = v.SampledObjects, v.SampledBytes
+		est[st] = a
+	}
+	return est
+}
+
+// sampled sets the sampled values of p to its objects and bytes.
+func sampled(p Profile) Profile {
+	for st, v := range p {
+		v.SampledObjects, v.SampledBytes = v.Objects, v.Bytes
+		p[st] = v
+	}
+	return p
+}
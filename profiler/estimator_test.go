@@ -0,0 +1,17 @@
+package profiler
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestNilEstimate checks that an estimator returning nil estimates no
+// allocations but keeps the raw samples.
+func TestNilEstimate(t *testing.T) {
+	p := NewGo(1, WithEstimator(func(Profile, EstimatorParams) Profile { return nil }))
+	p.Malloc(16, "alloc")
+	want := Alloc{SampledObjects: 1, SampledBytes: 16}
+	if got := p.Profile()["alloc"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
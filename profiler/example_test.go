@@ -0,0 +1,63 @@
+package profiler_test
+
+import (
+	"fmt"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+func ExampleNewPerfect() {
+	p := profiler.NewPerfect()
+	p.Malloc(64, "main;foo")
+	p.Malloc(64, "main;foo")
+	p.Malloc(512, "main;bar")
+	prof := p.Profile()
+	fmt.Println(prof["main;foo"].Objects, prof["main;foo"].Bytes)
+	fmt.Println(prof["main;bar"].Objects, prof["main;bar"].Bytes)
+	// Output:
+	// 2 128
+	// 1 512
+}
+
+func ExampleNewDotNet() {
+	p := profiler.NewDotNet(1024, profiler.WithFormula(profiler.ScaleLegacy))
+	for i := 0; i < 1000; i++ {
+		p.Malloc(64, "main;foo")
+	}
+	a := p.Profile()["main;foo"]
+	fmt.Println(a.SampledObjects, a.Objects, a.Bytes)
+	// Output: 63 1008 64512
+}
+
+func ExampleNewGo() {
+	p := profiler.NewGo(512*1024, profiler.WithSeed(42))
+	for i := 0; i < 100000; i++ {
+		p.Malloc(64, "main;foo")
+	}
+	a := p.Profile()["main;foo"]
+	fmt.Println(a.SampledObjects, a.Objects, a.Bytes)
+	// Output: 16 131080 8389120
+}
+
+// MallocN reports a run of identical allocations at once, which is much faster
+// than reporting them one at a time.
+func ExampleMallocN() {
+	p := profiler.NewGo(512*1024, profiler.WithSeed(42))
+	profiler.MallocN(p, 64, 100000, profiler.Intern("main;foo"))
+	a := p.Profile()["main;foo"]
+	fmt.Println(a.SampledObjects, a.Objects, a.Bytes)
+	// Output: 14 114695 7340480
+}
+
+// Estimators can be compared on the same samples by applying them to the raw
+// profile of a profiler.
+func ExampleScaleFormula_Estimator() {
+	p := profiler.NewGo(1024, profiler.WithSeed(42))
+	profiler.MallocN(p, 64, 100000, profiler.Intern("main;foo"))
+	raw := p.Raw()
+	params := profiler.EstimatorParams{Rate: p.Rate}
+	goEst := profiler.ScaleGo.Estimator(profiler.ScaleGo)(raw, params)
+	legacyEst := profiler.ScaleLegacy.Estimator(profiler.ScaleGo)(raw, params)
+	fmt.Println(raw["main;foo"].Objects, goEst["main;foo"].Objects, legacyEst["main;foo"].Objects)
+	// Output: 6162 101705 98592
+}
@@ -0,0 +1,166 @@
+package profiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Exec is a profiler implemented by an external program, which allows testing
+// sampler designs without modifying this repository. The program is started
+// lazily and communicates over stdin and stdout using a line based protocol.
+//
+// The simulator first writes a config line followed by one line per
+// allocation:
+//
+//	config rate=<bytes> seed=<int64> scale-formula=<formula>
+//	malloc <size> <stack>
+//	free <size> <stack>
+//	gc
+//	...
+//	profile
+//
+// After reading the profile line, the program must write its estimated
+// profile as one line per stack, terminated by an end line:
+//
+//	<objects> <bytes> <stack>
+//	...
+//	end
+//
+// The stack is always the remainder of the line. The profile has no in-use
+// values, which are reported as zero. Afterwards stdin is closed
+// and the program is expected to exit successfully. Anything the program
+// writes to stderr is passed through.
+type Exec struct {
+	ProfilerName string
+	Command      []string
+	Config       Config
+	Stderr       io.Writer
+
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	w       *bufio.Writer
+	r       *bufio.Reader
+	scratch []byte
+	err     error
+}
+
+func (p *Exec) Name() string { return p.ProfilerName }
+
+// Err returns the first error encountered while communicating with the
+// program.
+func (p *Exec) Err() error { return p.err }
+
+func (p *Exec) start() {
+	if p.cmd != nil || p.err != nil {
+		return
+	} else if len(p.Command) == 0 {
+		p.err = fmt.Errorf("%s: empty command", p.ProfilerName)
+		return
+	}
+	p.cmd = exec.Command(p.Command[0], p.Command[1:]...)
+	p.cmd.Stderr = p.Stderr
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		p.err = err
+		return
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		p.err = err
+		return
+	} else if err := p.cmd.Start(); err != nil {
+		p.err = fmt.Errorf("%s: %w", p.ProfilerName, err)
+		return
+	}
+	p.stdin = stdin
+	p.w = bufio.NewWriterSize(stdin, 64*1024)
+	p.r = bufio.NewReader(stdout)
+
+	var seed int64
+	if p.Config.Rand != nil {
+		seed = p.Config.Rand.Int63()
+	}
+	formula := p.Config.Formula
+	if formula == "" {
+		formula = ScaleHT
+	}
+	fmt.Fprintf(p.w, "config rate=%d seed=%d scale-formula=%s\n", p.Config.Rate, seed, formula)
+}
+
+func (p *Exec) Malloc(size int, stack StackTrace) { p.event("malloc ", size, stack) }
+func (p *Exec) Free(size int, stack StackTrace)   { p.event("free ", size, stack) }
+
+func (p *Exec) GC() {
+	if p.start(); p.err != nil {
+		return
+	} else if _, err := io.WriteString(p.w, "gc\n"); err != nil {
+		p.err = fmt.Errorf("%s: %w", p.ProfilerName, err)
+	}
+}
+
+func (p *Exec) event(kind string, size int, stack StackTrace) {
+	if p.start(); p.err != nil {
+		return
+	}
+	b := append(p.scratch[:0], kind...)
+	b = strconv.AppendInt(b, int64(size), 10)
+	b = append(b, ' ')
+	b = append(b, stack...)
+	b = append(b, '\n')
+	p.scratch = b
+	if _, err := p.w.Write(b); err != nil {
+		p.err = fmt.Errorf("%s: %w", p.ProfilerName, err)
+	}
+}
+
+func (p *Exec) Profile() Profile {
+	if p.start(); p.err != nil {
+		return nil
+	}
+	prof, err := p.readProfile()
+	p.stdin.Close()
+	if werr := p.cmd.Wait(); err == nil && werr != nil {
+		err = werr
+	}
+	if err != nil {
+		p.err = fmt.Errorf("%s: %w", p.ProfilerName, err)
+		return nil
+	}
+	return prof
+}
+
+func (p *Exec) readProfile() (Profile, error) {
+	if _, err := io.WriteString(p.w, "profile\n"); err != nil {
+		return nil, err
+	} else if err := p.w.Flush(); err != nil {
+		return nil, err
+	}
+	prof := Profile{}
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("reading profile: %w", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "end" {
+			return prof, nil
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("bad profile line: %q", line)
+		}
+		objects, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad profile line: %q", line)
+		}
+		bytes, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad profile line: %q", line)
+		}
+		prof.Add(StackTrace(fields[2]), Alloc{Objects: objects, Bytes: bytes})
+	}
+}
@@ -0,0 +1,111 @@
+package profiler
+
+import (
+	"hash/fnv"
+	"io"
+	"math"
+)
+
+// TruncateStacks returns a middleware that truncates stacks to their innermost
+// frames frames before the profiler sees them, see StackTrace.Truncate. This
+// merges deep stacks that only differ in their outer frames, which the
+// profiler then can't attribute correctly regardless of its sampling.
+func TruncateStacks(frames int) Middleware {
+	return MapStacks(func(s StackTrace) StackTrace { return s.Truncate(frames) })
+}
+
+// InlineFrames returns a middleware that removes the frames of the given
+// fraction of call sites from stacks before the profiler sees them, as if the
+// callee had been inlined into its caller and the profiler didn't expand
+// inlined frames. Whether a call site, i.e. a pair of caller and callee
+// frames, is inlined only depends on their names, so all profilers and runs
+// inline the same call sites. Allocations in inlined callees are attributed
+// to their callers, and stacks only differing in inlined frames are merged.
+func InlineFrames(fraction float64) Middleware {
+	return MapStacks(func(s StackTrace) StackTrace {
+		frames := s.Frames()
+		kept := frames[:1]
+		for i := 1; i < len(frames); i++ {
+			if !inlined(frames[i-1], frames[i], fraction) {
+				kept = append(kept, frames[i])
+			}
+		}
+		return NewStackTrace(kept...)
+	})
+}
+
+// inlined reports whether the call of callee by caller is among the given
+// fraction of inlined call sites.
+func inlined(caller, callee string, fraction float64) bool {
+	h := fnv.New64a()
+	io.WriteString(h, caller)
+	io.WriteString(h, frameSep)
+	io.WriteString(h, callee)
+	return float64(h.Sum64())/math.MaxUint64 < fraction
+}
+
+// MapStacks returns a middleware that replaces the stack of each allocation
+// and free with the result of f before the profiler sees it. f is called once
+// per stack.
+func MapStacks(f func(StackTrace) StackTrace) Middleware {
+	return func(p Profiler) Profiler { return &mapStacks{Wrapper: Wrapper{p}, f: f} }
+}
+
+// MapProfile returns p with its stacks replaced the way the middleware mws
+// replace them before the profiler sees them, see MapStacks, merging the
+// allocations of stacks that end up the same. Applied to the profile of the
+// reference profiler, it gives what a perfect profiler behind mws reports,
+// which tells the errors of sampling apart from those of attribution.
+// Middleware that doesn't map stacks is ignored.
+func MapProfile(p Profile, mws ...Middleware) Profile {
+	// Allocations pass the outermost middleware first.
+	var fs []func(StackTrace) StackTrace
+	for w := Chain(Wrapper{}, mws...); w != nil; {
+		if m, ok := w.(*mapStacks); ok {
+			fs = append(fs, m.f)
+		}
+		u, ok := w.(interface{ Unwrap() Profiler })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	if len(fs) == 0 {
+		return p
+	}
+	var mapped Profile
+	for st, a := range p {
+		for _, f := range fs {
+			st = f(st)
+		}
+		mapped.Add(st, a)
+	}
+	return mapped
+}
+
+type mapStacks struct {
+	Wrapper
+	f func(StackTrace) StackTrace
+	// ids caches the IDs of the mapped stacks, which is faster than mapping
+	// and interning them for every allocation.
+	ids map[StackID]StackID
+}
+
+func (p *mapStacks) id(id StackID) StackID {
+	if mid, ok := p.ids[id]; ok {
+		return mid
+	} else if p.ids == nil {
+		p.ids = map[StackID]StackID{}
+	}
+	mid := Intern(p.f(id.Stack()))
+	p.ids[id] = mid
+	return mid
+}
+
+func (p *mapStacks) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+func (p *mapStacks) MallocID(size int, id StackID)     { MallocID(p.Profiler, size, p.id(id)) }
+func (p *mapStacks) MallocN(size int, count int64, id StackID) {
+	MallocN(p.Profiler, size, count, p.id(id))
+}
+func (p *mapStacks) Free(size int, stack StackTrace) { p.FreeID(size, Intern(stack)) }
+func (p *mapStacks) FreeID(size int, id StackID)     { FreeID(p.Profiler, size, p.id(id)) }
@@ -0,0 +1,30 @@
+package profiler
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestMapProfile checks that mapping the profile of a perfect profiler gives
+// the profile of a perfect profiler behind the same middleware.
+func TestMapProfile(t *testing.T) {
+	spec, _ := Lookup("perfect")
+	// Stacks are truncated to their innermost two frames first, and then
+	// the inner of them is inlined.
+	mws := []Middleware{InlineFrames(1), RoundSizes(), TruncateStacks(2)}
+	plain := spec.New(Config{})
+	wrapped := Chain(spec.New(Config{}), mws...)
+	for i, st := range []StackTrace{"main", "main;a", "main;b;c", "main;a;b;c", "main;d;b;c", "main;a;b;c;d"} {
+		for _, p := range []Profiler{plain, wrapped} {
+			MallocN(p, 16, int64(i+1), Intern(st))
+		}
+	}
+	// RoundSizes doesn't change the size class 16, so only the stacks
+	// differ.
+	if got, want := MapProfile(plain.Profile(), mws...), wrapped.Profile(); !reflect.DeepEqual(got, want) {
+		t.Errorf("MapProfile gives %v, want %v", got, want)
+	}
+	if got := MapProfile(plain.Profile(), RoundSizes()); !reflect.DeepEqual(got, plain.Profile()) {
+		t.Errorf("MapProfile without stack mapping middleware gives %v, want %v", got, plain.Profile())
+	}
+}
@@ -0,0 +1,55 @@
+package profiler
+
+import (
+	"math"
+	"strconv"
+)
+
+// FuzzWarmup is the stack of the first allocation of every FuzzStream.
+// Profilers that start with a sampling distance of zero always sample the
+// first allocation, so it goes to a stack that fuzzers don't check.
+var FuzzWarmup = Intern("warmup")
+
+// FuzzMaxAllocs caps the allocations of a FuzzStream.
+const FuzzMaxAllocs = 1 << 14
+
+// FuzzRun is a run of allocations of the same size at the same stack.
+type FuzzRun struct {
+	Size  int
+	Count int64
+	ID    StackID
+}
+
+// FuzzStream returns the runs of allocations encoded by data, starting with a
+// single allocation at FuzzWarmup, or nil if data is shorter than 2 bytes. Its
+// first byte selects the number of stacks, up to 8, each of which allocates a
+// single size encoded log-uniformly between 1 byte and 4 times the rate by the
+// following bytes. This matches profilers that scale by the average object
+// size of a stack. Each remaining byte allocates up to 32 objects at one of
+// the stacks, up to FuzzMaxAllocs in total.
+func FuzzStream(rate int, data []byte) []FuzzRun {
+	if len(data) < 2 {
+		return nil
+	}
+	sites := make([]FuzzRun, 1+int(data[0])%8)
+	data = data[1:]
+	for i := range sites {
+		var b byte
+		if i < len(data) {
+			b = data[i]
+		}
+		size := int(math.Exp(float64(b) / 255 * math.Log(4*float64(rate))))
+		sites[i] = FuzzRun{Size: size, ID: Intern(StackTrace("stack" + strconv.Itoa(i)))}
+	}
+	runs := []FuzzRun{{Size: 1, Count: 1, ID: FuzzWarmup}}
+	var allocs int64
+	for _, b := range data {
+		run := sites[int(b)%len(sites)]
+		run.Count = 1 + int64(b)/8
+		if allocs += run.Count; allocs > FuzzMaxAllocs {
+			break
+		}
+		runs = append(runs, run)
+	}
+	return runs
+}
@@ -0,0 +1,87 @@
+package profiler
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/felixge/alloc-prof-sim/stats"
+)
+
+// fuzzProfilers are the stochastic samplers checked by FuzzEstimates. The
+// deterministic ones give the same estimate in every trial, so their mean has
+// no confidence interval.
+var fuzzProfilers = []string{"go", "go-bucket", "go-remainder", "go-sample"}
+
+const (
+	fuzzTrials = 30
+	// fuzzMinSampled is the number of trials that must have sampled a stack
+	// to check it. The estimates of rarely sampled stacks are too skewed for
+	// the confidence interval of their mean.
+	fuzzMinSampled = 10
+	// fuzzAlpha is the probability of a false positive among all checks of
+	// an input. Fuzzing runs many inputs, so it is small.
+	fuzzAlpha = 1e-6
+)
+
+// FuzzEstimates checks for the allocation streams built from the fuzz input
+// that the true objects and bytes of each stack lie within the confidence
+// interval of the mean estimate of each stochastic sampler across trials, like
+// the fuzz command. The confidence level is Bonferroni corrected for the
+// number of checks, so failures indicate a biased estimator rather than bad
+// luck.
+func FuzzEstimates(f *testing.F) {
+	f.Add(uint8(4), int64(1), []byte{3, 10, 128, 250, 0, 1, 2, 3, 4, 5, 255, 254})
+	f.Add(uint8(0), int64(2), []byte{0, 255, 255, 255})
+	f.Add(uint8(9), int64(3), []byte{7, 1, 2, 3, 4, 5, 6, 7, 8, 100, 101, 102, 103, 104, 105, 106, 107})
+	f.Fuzz(func(t *testing.T, rateShift uint8, seed int64, data []byte) {
+		rate := 64 << (rateShift % 10)
+		runs := FuzzStream(rate, data)
+		if runs == nil {
+			t.Skip("too short")
+		}
+		var want Profile
+		for _, run := range runs {
+			want.Add(run.ID.Stack(), Alloc{Objects: run.Count, Bytes: run.Count * int64(run.Size)})
+		}
+		delete(want, FuzzWarmup.Stack())
+
+		level := 1 - fuzzAlpha/float64(2*len(want)*len(fuzzProfilers))
+		for _, name := range fuzzProfilers {
+			spec, _ := Lookup(name)
+			objects := map[StackTrace][]float64{}
+			bytes := map[StackTrace][]float64{}
+			for trial := int64(0); trial < fuzzTrials; trial++ {
+				p := spec.New(Config{Formula: ScaleHT, Rate: rate, Rand: rand.New(rand.NewSource(seed + trial))})
+				for _, run := range runs {
+					MallocN(p, run.Size, run.Count, run.ID)
+				}
+				got := p.Profile()
+				for st := range want {
+					objects[st] = append(objects[st], float64(got[st].Objects))
+					bytes[st] = append(bytes[st], float64(got[st].Bytes))
+				}
+			}
+			for st, a := range want {
+				for _, m := range []struct {
+					name string
+					got  []float64
+					want int64
+				}{{"objects", objects[st], a.Objects}, {"bytes", bytes[st], a.Bytes}} {
+					sampled := 0
+					for _, v := range m.got {
+						if v != 0 {
+							sampled++
+						}
+					}
+					mean, lo, hi, result := stats.CheckMean(m.got, float64(m.want), level)
+					// Stacks allocating sizes far beyond the rate may be
+					// sampled completely in every trial, which leaves the
+					// interval without width.
+					if result == stats.CheckFailed && sampled >= fuzzMinSampled && lo < hi {
+						t.Errorf("%s rate=%d stack=%s: %s mean %.0f want %d, interval [%.0f, %.0f]", name, rate, st, m.name, mean, m.want, lo, hi)
+					}
+				}
+			}
+		}
+	})
+}
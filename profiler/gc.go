@@ -0,0 +1,111 @@
+package profiler
+
+// TriggerGC returns a middleware that reports the end of a garbage collection
+// cycle to the profiler each time another bytes bytes have been allocated,
+// after the allocation reaching them. This simulates GC cycles for workloads
+// that don't have any.
+func TriggerGC(bytes int64) Middleware {
+	return func(p Profiler) Profiler { return &triggerGC{Wrapper: Wrapper{p}, every: bytes, left: bytes} }
+}
+
+type triggerGC struct {
+	Wrapper
+	every, left int64
+}
+
+func (p *triggerGC) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+
+func (p *triggerGC) MallocID(size int, id StackID) {
+	MallocID(p.Profiler, size, id)
+	p.count(int64(size))
+}
+
+func (p *triggerGC) MallocN(size int, count int64, id StackID) {
+	for count > 0 {
+		// n allocations reach the next cycle, or all of them if they
+		// don't.
+		n := count
+		if size > 0 {
+			if k := (p.left + int64(size) - 1) / int64(size); k < n {
+				n = k
+			}
+		}
+		MallocN(p.Profiler, size, n, id)
+		p.count(n * int64(size))
+		count -= n
+	}
+}
+
+func (p *triggerGC) FreeID(size int, id StackID) { FreeID(p.Profiler, size, id) }
+
+func (p *triggerGC) count(bytes int64) {
+	if p.left -= bytes; p.left <= 0 {
+		p.left = p.every
+		GC(p.Profiler)
+	}
+}
+
+// Stage returns a middleware that publishes events to the profiler in stages
+// aligned to GC cycles, like the memRecord of the Go runtime: allocations
+// become visible at the end of the cycle after the one they happened in and
+// frees at the end of their own cycle, see runtime/mprof.go. The profile thus
+// lacks the allocations of the last one or two cycles, and all of them if
+// there are no GC cycles. Objects can't be freed in the cycle that allocated
+// them in the runtime. If they are, their frees are published before the
+// allocations and ignored by sampling profilers.
+//
+// Stage holds the events of the unpublished cycles in memory, in order, so
+// that the profiler samples them like it would without it.
+func Stage() Middleware {
+	return func(p Profiler) Profiler { return &stage{Wrapper: Wrapper{p}} }
+}
+
+type stage struct {
+	Wrapper
+	cycle  int
+	future [3][]stagedEvent
+}
+
+// stagedEvent is count allocations, or a free if count is 0.
+type stagedEvent struct {
+	size  int
+	count int64
+	id    StackID
+}
+
+func (p *stage) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+func (p *stage) MallocID(size int, id StackID)     { p.MallocN(size, 1, id) }
+
+func (p *stage) MallocN(size int, count int64, id StackID) {
+	if count > 0 {
+		p.add(2, stagedEvent{size: size, count: count, id: id})
+	}
+}
+
+func (p *stage) Free(size int, stack StackTrace) { p.FreeID(size, Intern(stack)) }
+func (p *stage) FreeID(size int, id StackID)     { p.add(1, stagedEvent{size: size, id: id}) }
+
+// add records e for publication at the end of the given number of cycles from
+// the current one.
+func (p *stage) add(cycles int, e stagedEvent) {
+	i := (p.cycle + cycles) % len(p.future)
+	p.future[i] = append(p.future[i], e)
+}
+
+// GC ends the current cycle and publishes the events due.
+func (p *stage) GC() {
+	p.cycle++
+	i := p.cycle % len(p.future)
+	for _, e := range p.future[i] {
+		switch e.count {
+		case 0:
+			FreeID(p.Profiler, e.size, e.id)
+		case 1:
+			MallocID(p.Profiler, e.size, e.id)
+		default:
+			MallocN(p.Profiler, e.size, e.count, e.id)
+		}
+	}
+	p.future[i] = p.future[i][:0]
+	GC(p.Profiler)
+}
@@ -0,0 +1,78 @@
+package profiler
+
+// AddOverhead returns a middleware that adds bytes of allocator metadata, such
+// as an object header, to the size of each allocation and free, so that the
+// profiler sees heap bytes rather than the requested ones.
+func AddOverhead(bytes int) Middleware {
+	return func(p Profiler) Profiler {
+		return mapSizes{Wrapper{p}, func(size int) int { return size + bytes }}
+	}
+}
+
+// mapSizes passes the size of each allocation and free through f.
+type mapSizes struct {
+	Wrapper
+	f func(size int) int
+}
+
+func (p mapSizes) Malloc(size int, stack StackTrace) { p.Profiler.Malloc(p.f(size), stack) }
+func (p mapSizes) MallocID(size int, id StackID)     { MallocID(p.Profiler, p.f(size), id) }
+func (p mapSizes) MallocN(size int, count int64, id StackID) {
+	MallocN(p.Profiler, p.f(size), count, id)
+}
+func (p mapSizes) Free(size int, stack StackTrace) { Free(p.Profiler, p.f(size), stack) }
+func (p mapSizes) FreeID(size int, id StackID)     { FreeID(p.Profiler, p.f(size), id) }
+
+// ReportRequested returns a middleware that passes the heap size of each
+// allocation and free to the profiler, as returned by heap, but reports its
+// profile in requested bytes. The byte estimates of each stack are scaled by
+// the ratio of the requested to the heap bytes of its samples, which are told
+// apart by the number of samples of the profiler before and after each
+// allocation. Stacks without samples keep their heap bytes.
+func ReportRequested(heap func(size int) int) Middleware {
+	return func(p Profiler) Profiler {
+		return &reportRequested{Wrapper: Wrapper{p}, heap: heap, requested: map[StackID]int64{}}
+	}
+}
+
+type reportRequested struct {
+	Wrapper
+	heap func(size int) int
+	// requested holds the requested bytes of the samples of each stack.
+	requested map[StackID]int64
+}
+
+func (p *reportRequested) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+func (p *reportRequested) MallocID(size int, id StackID)     { p.MallocN(size, 1, id) }
+
+func (p *reportRequested) MallocN(size int, count int64, id StackID) {
+	before := p.Samples()
+	if count == 1 {
+		MallocID(p.Profiler, p.heap(size), id)
+	} else {
+		MallocN(p.Profiler, p.heap(size), count, id)
+	}
+	if n := p.Samples() - before; n > 0 {
+		p.requested[id] += n * int64(size)
+	}
+}
+
+func (p *reportRequested) Free(size int, stack StackTrace) { Free(p.Profiler, p.heap(size), stack) }
+func (p *reportRequested) FreeID(size int, id StackID)     { FreeID(p.Profiler, p.heap(size), id) }
+
+func (p *reportRequested) Profile() Profile {
+	prof := p.Profiler.Profile()
+	for id, bytes := range p.requested {
+		st := id.Stack()
+		a, ok := prof[st]
+		if !ok || a.SampledBytes == 0 {
+			continue
+		}
+		ratio := float64(bytes) / float64(a.SampledBytes)
+		a.Bytes = int64(float64(a.Bytes) * ratio)
+		a.InUseBytes = int64(float64(a.InUseBytes) * ratio)
+		a.SampledBytes = bytes
+		prof[st] = a
+	}
+	return prof
+}
@@ -0,0 +1,163 @@
+package profiler
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// SizeBuckets is the number of buckets of a SizeHistogram.
+const SizeBuckets = 64
+
+// SizeHistogram counts the objects of a stack by size in power-of-two
+// buckets. Bucket i holds the sizes from 1<<(i-1) up to 1<<i - 1, and bucket
+// 0 the size 0. Trailing empty buckets are omitted.
+type SizeHistogram []int64
+
+// SizeBucket returns the bucket of size.
+func SizeBucket(size int) int { return bits.Len(uint(size)) }
+
+// bucketMin returns the smallest size of bucket i.
+func bucketMin(i int) int64 {
+	if i == 0 {
+		return 0
+	}
+	return 1 << (i - 1)
+}
+
+// plus returns the sum of h and o in a new histogram, so that profiles never
+// share them.
+func (h SizeHistogram) plus(o SizeHistogram) SizeHistogram {
+	n := len(h)
+	if len(o) > n {
+		n = len(o)
+	}
+	sum := make(SizeHistogram, n)
+	copy(sum, h)
+	for i, v := range o {
+		sum[i] += v
+	}
+	return sum
+}
+
+// Floats returns the counts of h padded to n buckets, for comparing
+// histograms of different lengths.
+func (h SizeHistogram) Floats(n int) []float64 {
+	f := make([]float64, n)
+	for i, v := range h {
+		f[i] = float64(v)
+	}
+	return f
+}
+
+// String formats the non-empty buckets of h as the smallest size of each
+// followed by its count, e.g. "16:1000 128:1000".
+func (h SizeHistogram) String() string {
+	var s strings.Builder
+	for i, v := range h {
+		if v == 0 {
+			continue
+		}
+		if s.Len() > 0 {
+			s.WriteByte(' ')
+		}
+		fmt.Fprintf(&s, "%d:%d", bucketMin(i), v)
+	}
+	return s.String()
+}
+
+// WithSizeHistograms sets whether profilers report a SizeHistogram of the objects
+// allocated at each stack. Sampling profilers weight each sample by the
+// inverse probability of sampling its size and distribute the estimated
+// objects of its stack across the buckets by these weights, so the histogram
+// of a stack allocating a single size holds its estimate. This costs a
+// lookup for every sample, which is why it is disabled by default.
+func WithSizeHistograms(enabled bool) Option {
+	return func(c *Config) { c.SizeHistograms = enabled }
+}
+
+// sizeWeights holds the weights of the samples of each stack by size bucket.
+// A nil *sizeWeights ignores all calls.
+type sizeWeights struct {
+	m map[StackID]*[SizeBuckets]float64
+	// weight returns the weight of a sample of size, or nil for 1.
+	weight func(size int) float64
+}
+
+func newSizeWeights(enabled bool, weight func(size int) float64) *sizeWeights {
+	if !enabled {
+		return nil
+	}
+	return &sizeWeights{m: map[StackID]*[SizeBuckets]float64{}, weight: weight}
+}
+
+// add records n samples of size.
+func (s *sizeWeights) add(id StackID, size int, n int64) {
+	if s == nil {
+		return
+	}
+	weight := 1.0
+	if s.weight != nil {
+		weight = s.weight(size)
+	}
+	s.addWeighted(id, size, n, weight)
+}
+
+// addWeighted records n samples of size, each with weight.
+func (s *sizeWeights) addWeighted(id StackID, size int, n int64, weight float64) {
+	if s == nil {
+		return
+	}
+	w := s.m[id]
+	if w == nil {
+		w = &[SizeBuckets]float64{}
+		s.m[id] = w
+	}
+	w[SizeBucket(size)] += float64(n) * weight
+}
+
+func (s *sizeWeights) merge(o *sizeWeights) {
+	if s == nil || o == nil {
+		return
+	}
+	for id, ow := range o.m {
+		for i, v := range ow {
+			if v != 0 {
+				s.addWeighted(id, int(bucketMin(i)), 1, v)
+			}
+		}
+	}
+}
+
+// apply sets the histogram of each stack of p with samples to its objects
+// distributed across the buckets by their weights.
+func (s *sizeWeights) apply(p Profile) Profile {
+	if s == nil {
+		return p
+	}
+	for id, w := range s.m {
+		st := id.Stack()
+		a, ok := p[st]
+		if !ok {
+			continue
+		}
+		var total float64
+		n := 0
+		for i, v := range w {
+			if total += v; v != 0 {
+				n = i + 1
+			}
+		}
+		if total <= 0 {
+			continue
+		}
+		h := make(SizeHistogram, n)
+		for i := range h {
+			h[i] = int64(math.Round(float64(a.Objects) * w[i] / total))
+		}
+		a.Sizes = h
+		p[st] = a
+	}
+	return p
+}
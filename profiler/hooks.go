@@ -0,0 +1,74 @@
+package profiler
+
+import "strings"
+
+// FreeProfiler is implemented by profilers that track frees, e.g. in order to
+// report in-use memory.
+type FreeProfiler interface {
+	Profiler
+	Free(size int, stack StackTrace)
+}
+
+// GCAwareProfiler is implemented by profilers whose behavior depends on
+// garbage collection cycles, e.g. because they only publish samples at the end
+// of a cycle.
+type GCAwareProfiler interface {
+	Profiler
+	GC()
+}
+
+// Free reports the freeing of an object of the given size allocated at stack to
+// p. It does nothing if p doesn't implement FreeProfiler.
+func Free(p Profiler, size int, stack StackTrace) {
+	if fp, ok := p.(FreeProfiler); ok {
+		fp.Free(size, stack)
+	}
+}
+
+// GC reports the end of a garbage collection cycle to p. It does nothing if p
+// doesn't implement GCAwareProfiler.
+func GC(p Profiler) {
+	if gp, ok := p.(GCAwareProfiler); ok {
+		gp.GC()
+	}
+}
+
+// Multi fans out all events to each of its profilers, which lets a workload
+// generate its events once for many profilers. Its own profile is always nil,
+// the profiles of the individual profilers hold the results. Use AsIDProfiler
+// to add profilers that don't implement IDProfiler.
+type Multi []IDProfiler
+
+func (m Multi) Name() string {
+	names := make([]string, len(m))
+	for i, p := range m {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (m Multi) Malloc(size int, stack StackTrace) {
+	for _, p := range m {
+		p.Malloc(size, stack)
+	}
+}
+
+func (m Multi) MallocID(size int, id StackID) {
+	for _, p := range m {
+		p.MallocID(size, id)
+	}
+}
+
+func (m Multi) Free(size int, stack StackTrace) {
+	for _, p := range m {
+		Free(p, size, stack)
+	}
+}
+
+func (m Multi) GC() {
+	for _, p := range m {
+		GC(p)
+	}
+}
+
+func (m Multi) Profile() Profile { return nil }
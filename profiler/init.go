@@ -0,0 +1,73 @@
+package profiler
+
+import "fmt"
+
+// SampleInit selects the distance to the first sample of a sampling profiler.
+type SampleInit string
+
+const (
+	// InitZero starts with a distance of zero, so the first allocation is
+	// always sampled. It is the default.
+	InitZero SampleInit = "zero"
+	// InitRate starts with a distance of Rate, as if a sample had just been
+	// taken.
+	InitRate SampleInit = "rate"
+	// InitExp draws the distance from the exponential distribution with a
+	// mean of Rate, like the Go runtime does for each mcache.
+	InitExp SampleInit = "exp"
+)
+
+// SampleInits lists all initializations.
+var SampleInits = []SampleInit{InitZero, InitRate, InitExp}
+
+// ParseSampleInit returns the initialization with the given name.
+func ParseSampleInit(s string) (SampleInit, error) {
+	for _, v := range SampleInits {
+		if string(v) == s {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("unknown sample init: %q", s)
+}
+
+// WithInit sets the distance to the first sample of sampling profilers.
+func WithInit(init SampleInit) Option {
+	return func(c *Config) { c.Init = init }
+}
+
+// WithWarmup sets whether sampling profilers that start with a distance of
+// zero count their first sample as the single allocation it is, as it is
+// certain rather than drawn, instead of scaling it like the others. This
+// corrects the cold start bias of InitZero, which overestimates the stack of
+// the first allocation by about rate/size objects, most visibly in short
+// runs. The correction ignores the free of that allocation.
+func WithWarmup(warmup bool) Option {
+	return func(c *Config) { c.Warmup = warmup }
+}
+
+// distance returns the distance to the first sample for rate, drawing from
+// exp for InitExp.
+func (init SampleInit) distance(rate int, exp func() float64) int {
+	switch init {
+	case InitRate:
+		return rate
+	case InitExp:
+		return int(float64(rate) * exp())
+	}
+	return 0
+}
+
+// certain reports whether the first sample is certain and should be counted
+// as such with warmup.
+func (init SampleInit) certain(warmup bool) bool {
+	return warmup && (init == InitZero || init == "")
+}
+
+// withWarm adds the certain first samples in warm to the estimates of p as is,
+// see WithWarmup.
+func withWarm(p Profile, warm *counts) Profile {
+	for st, a := range warm.profile() {
+		p.Add(st, Alloc{Objects: a.Objects, Bytes: a.Bytes, InUseObjects: a.Objects, InUseBytes: a.Bytes, SampledObjects: a.Objects, SampledBytes: a.Bytes})
+	}
+	return p
+}
@@ -0,0 +1,138 @@
+package profiler
+
+import "math/rand"
+
+// IDFreeProfiler is implemented by profilers that accept interned stacks for
+// frees, see IDProfiler.
+type IDFreeProfiler interface {
+	FreeProfiler
+	FreeID(size int, id StackID)
+}
+
+// FreeID reports the freeing of an object of the given size allocated at the
+// interned stack id to p, falling back to Free if p doesn't implement
+// IDFreeProfiler.
+func FreeID(p Profiler, size int, id StackID) {
+	if fp, ok := p.(IDFreeProfiler); ok {
+		fp.FreeID(size, id)
+	} else {
+		Free(p, size, id.Stack())
+	}
+}
+
+func (m Multi) FreeID(size int, id StackID) {
+	for _, p := range m {
+		FreeID(p, size, id)
+	}
+}
+
+// WithInUse sets whether sampling profilers track which of their samples
+// haven't been freed, so that they estimate the memory in use. This costs a
+// lookup for every allocation, which is why it is disabled by default.
+// Without it sampling profilers ignore frees.
+func WithInUse(inUse bool) Option {
+	return func(c *Config) { c.InUse = inUse }
+}
+
+func (p *Perfect) Free(size int, stack StackTrace) { p.FreeID(size, Intern(stack)) }
+func (p *Perfect) FreeID(size int, id StackID)     { p.freed.add(id, 1, int64(size)) }
+
+func (p *DotNet) Free(size int, stack StackTrace) { p.FreeID(size, Intern(stack)) }
+func (p *DotNet) FreeID(size int, id StackID) {
+	if p.live.free(p.Rand, id, size) {
+		p.freed.add(id, 1, int64(size))
+		if p.PerSample {
+			p.est.free(id, size, p.factor(size))
+		}
+	}
+}
+
+func (p *Go) Free(size int, stack StackTrace) { p.FreeID(size, Intern(stack)) }
+func (p *Go) FreeID(size int, id StackID) {
+	if p.live.free(p.Rand, id, size) {
+		p.freed.add(id, 1, int64(size))
+		if p.Buckets {
+			p.sized.free(id, size)
+		}
+		if p.PerSample {
+			p.est.free(id, size, p.factor(size))
+		}
+	}
+}
+
+// liveSet counts the live objects of each stack and size, and how many of them
+// were sampled. A nil *liveSet ignores all calls.
+type liveSet struct {
+	m map[liveKey]liveCount
+}
+
+type liveKey struct {
+	id   StackID
+	size int
+}
+
+type liveCount struct {
+	objects, samples int64
+}
+
+func newLiveSet(enabled bool) *liveSet {
+	if !enabled {
+		return nil
+	}
+	return &liveSet{m: map[liveKey]liveCount{}}
+}
+
+// add records objects new live objects of which samples were sampled.
+func (l *liveSet) add(id StackID, size int, objects, samples int64) {
+	if l == nil {
+		return
+	}
+	k := liveKey{id, size}
+	c := l.m[k]
+	c.objects += objects
+	c.samples += samples
+	l.m[k] = c
+}
+
+// free removes a live object and reports whether it was sampled. Frees don't
+// say which object they free, so the object is picked at random among the
+// live ones of the same stack and size. Frees of objects that were never
+// allocated are ignored.
+func (l *liveSet) free(r *rand.Rand, id StackID, size int) (sampled bool) {
+	if l == nil {
+		return false
+	}
+	k := liveKey{id, size}
+	c, ok := l.m[k]
+	if !ok || c.objects == 0 {
+		return false
+	}
+	sampled = c.samples > 0 && r.Int63n(c.objects) < c.samples
+	c.objects--
+	if sampled {
+		c.samples--
+	}
+	l.m[k] = c
+	return sampled
+}
+
+func (l *liveSet) merge(o *liveSet) {
+	if l == nil || o == nil {
+		return
+	}
+	for k, c := range o.m {
+		l.add(k.id, k.size, c.objects, c.samples)
+	}
+}
+
+// inUse sets the in-use values of p to its allocations minus freed.
+func inUse(p, freed Profile) Profile {
+	for st, v := range p {
+		v.InUseObjects, v.InUseBytes = v.Objects, v.Bytes
+		p[st] = v
+	}
+	for st, f := range freed {
+		p.Add(st, Alloc{InUseObjects: -f.Objects, InUseBytes: -f.Bytes})
+	}
+	return p
+}
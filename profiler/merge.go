@@ -0,0 +1,96 @@
+package profiler
+
+import "fmt"
+
+// Merger is implemented by profilers that can take over the samples of another
+// profiler of the same type and configuration, e.g. one that simulated
+// another part of the same workload. The samples are merged before they are
+// estimated, so the resulting profile is the one a single profiler taking all
+// of them would report.
+//
+// Resume puts a new profiler into the state of one that has already seen many
+// allocations, so that a profiler simulating a later part of a workload
+// doesn't treat its first allocation like the first of the program.
+type Merger interface {
+	Profiler
+	Merge(other Profiler) error
+	Resume()
+}
+
+func (p *Perfect) Resume() {}
+
+// Resume starts as if a sample had just been taken.
+func (p *DotNet) Resume() { p.nextSample, p.certain = p.Rate, false }
+
+// Resume draws the distance to the first sample, which is exponentially
+// distributed at any point of the stream as the sampling is memoryless. With
+// Uniform it is drawn like after a sample, as old runtimes did for each new
+// mcache.
+func (p *Go) Resume() {
+	p.certain = false
+	if p.Uniform {
+		p.nextSample = p.uniform()
+		return
+	}
+	p.nextSample = int(float64(p.Rate) * p.exp())
+}
+
+func (p *Perfect) Merge(other Profiler) error {
+	o, ok := other.(*Perfect)
+	if !ok {
+		return mergeError(p, other)
+	}
+	p.prof.merge(o.prof)
+	p.freed.merge(o.freed)
+	p.sizes.merge(o.sizes)
+	return nil
+}
+
+func (p *DotNet) Merge(other Profiler) error {
+	o, ok := other.(*DotNet)
+	if !ok || o.Rate != p.Rate || o.Naive != p.Naive || o.PerSample != p.PerSample {
+		return mergeError(p, other)
+	}
+	p.prof.merge(&o.prof)
+	p.freed.merge(&o.freed)
+	p.live.merge(o.live)
+	p.est.merge(o.est)
+	p.warm.merge(&o.warm)
+	p.sizes.merge(o.sizes)
+	return nil
+}
+
+func (p *Go) Merge(other Profiler) error {
+	o, ok := other.(*Go)
+	if !ok || o.Rate != p.Rate || o.Buckets != p.Buckets || o.Remainder != p.Remainder || o.PerSample != p.PerSample || o.Uniform != p.Uniform {
+		return mergeError(p, other)
+	}
+	p.prof.merge(&o.prof)
+	p.freed.merge(&o.freed)
+	p.live.merge(o.live)
+	p.sized.merge(o.sized)
+	p.est.merge(o.est)
+	p.warm.merge(&o.warm)
+	p.sizes.merge(o.sizes)
+	return nil
+}
+
+func mergeError(p, other Profiler) error {
+	return fmt.Errorf("can't merge %s profiler into %s profiler", other.Name(), p.Name())
+}
+
+func (c *denseCounts) merge(o denseCounts) {
+	for id, v := range o {
+		if v.Objects != 0 || v.Bytes != 0 {
+			c.add(StackID(id), v.Objects, v.Bytes)
+		}
+	}
+}
+
+func (c *counts) merge(o *counts) {
+	for _, s := range o.slots {
+		if s.key != 0 {
+			c.add(s.key-1, s.alloc.Objects, s.alloc.Bytes)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package profiler
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestMergeScale checks for every registered profiler and scale formula that
+// merging the samples of several profilers and then scaling them agrees with
+// scaling the samples of each profiler and then merging the estimates, up to
+// the truncation of each estimate to whole numbers. This only holds for stacks
+// allocating a single size, see Profile.Merge.
+func TestMergeScale(t *testing.T) {
+	const rate = 1024
+	sites := []struct {
+		size int
+		id   StackID
+	}{{16, Intern("small")}, {700, Intern("medium")}, {5000, Intern("big")}}
+	for _, spec := range Specs() {
+		for _, formula := range ScaleFormulas {
+			var parts []Profiler
+			for seed := int64(0); seed < 3; seed++ {
+				p := spec.New(Config{Formula: formula, Rate: rate, Rand: rand.New(rand.NewSource(seed))})
+				for i, site := range sites {
+					MallocN(p, site.size, 100*(seed+1)*int64(i+1), site.id)
+				}
+				parts = append(parts, p)
+			}
+
+			var scaled Profile
+			for _, p := range parts {
+				scaled.Merge(p.Profile())
+			}
+			merged := parts[0].(Merger)
+			for _, p := range parts[1:] {
+				if err := merged.Merge(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := merged.Profile()
+			for _, site := range sites {
+				st := site.id.Stack()
+				if !within(got[st].Objects, scaled[st].Objects, len(parts)) || !within(got[st].Bytes, scaled[st].Bytes, len(parts)) {
+					t.Errorf("%s %s %s: merging then scaling gives %d objects and %d bytes, scaling then merging %d and %d", spec.Name, formula, st, got[st].Objects, got[st].Bytes, scaled[st].Objects, scaled[st].Bytes)
+				}
+			}
+		}
+	}
+}
+
+// within reports whether a and b differ by at most d.
+func within(a, b int64, d int) bool {
+	return a-b <= int64(d) && b-a <= int64(d)
+}
@@ -0,0 +1,243 @@
+package profiler
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Middleware wraps a profiler to add behavior such as dropping events, much
+// like io.Reader decorators.
+type Middleware func(Profiler) Profiler
+
+// Chain wraps p with mws in order, so the last middleware is the outermost.
+func Chain(p Profiler, mws ...Middleware) Profiler {
+	for _, mw := range mws {
+		p = mw(p)
+	}
+	return p
+}
+
+// Wrapper forwards all calls to the wrapped profiler, including those of the
+// optional interfaces. Middleware can embed it and override methods. Wrapper
+// doesn't implement IDProfiler, so that middleware overriding Malloc sees all
+// allocations unless it implements MallocID itself.
+type Wrapper struct {
+	Profiler
+}
+
+func (w Wrapper) Free(size int, stack StackTrace) { Free(w.Profiler, size, stack) }
+func (w Wrapper) GC()                             { GC(w.Profiler) }
+
+// Unwrap returns the wrapped profiler.
+func (w Wrapper) Unwrap() Profiler { return w.Profiler }
+
+// Samples returns the number of samples taken by the wrapped profiler, or 0 if
+// it doesn't report them.
+func (w Wrapper) Samples() int64 {
+	if s, ok := w.Profiler.(interface{ Samples() int64 }); ok {
+		return s.Samples()
+	}
+	return 0
+}
+
+func (w Wrapper) Err() error {
+	if e, ok := w.Profiler.(interface{ Err() error }); ok {
+		return e.Err()
+	}
+	return nil
+}
+
+// DropEvents returns a middleware that drops each allocation with probability
+// prob before the profiler sees it, e.g. to model lost events.
+func DropEvents(prob float64, r *rand.Rand) Middleware {
+	return func(p Profiler) Profiler { return &dropEvents{Wrapper: Wrapper{p}, prob: prob, rand: r} }
+}
+
+type dropEvents struct {
+	Wrapper
+	prob float64
+	rand *rand.Rand
+}
+
+func (p *dropEvents) Malloc(size int, stack StackTrace) {
+	if p.rand.Float64() >= p.prob {
+		p.Profiler.Malloc(size, stack)
+	}
+}
+
+func (p *dropEvents) MallocID(size int, id StackID) {
+	if p.rand.Float64() >= p.prob {
+		MallocID(p.Profiler, size, id)
+	}
+}
+
+// SampleBudget returns a middleware that stops passing allocations to the
+// profiler once it has taken n samples. The profiler must report its samples.
+func SampleBudget(n int64) Middleware {
+	return func(p Profiler) Profiler { return &sampleBudget{Wrapper: Wrapper{p}, n: n} }
+}
+
+type sampleBudget struct {
+	Wrapper
+	n int64
+}
+
+func (p *sampleBudget) Malloc(size int, stack StackTrace) {
+	if p.Samples() < p.n {
+		p.Profiler.Malloc(size, stack)
+	}
+}
+
+func (p *sampleBudget) MallocID(size int, id StackID) {
+	if p.Samples() < p.n {
+		MallocID(p.Profiler, size, id)
+	}
+}
+
+// MiddlewareFactory describes how to create a registered middleware from an
+// optional argument.
+type MiddlewareFactory struct {
+	Params      string
+	Description string
+	New         func(arg string, c Config) (Middleware, error)
+}
+
+// MiddlewareSpec is a registered middleware.
+type MiddlewareSpec struct {
+	Name string
+	MiddlewareFactory
+}
+
+var (
+	middlewareMu sync.RWMutex
+	middlewares  []MiddlewareSpec
+)
+
+// RegisterMiddleware makes a middleware available under name. It panics if
+// name is already registered.
+func RegisterMiddleware(name string, f MiddlewareFactory) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	for _, spec := range middlewares {
+		if spec.Name == name {
+			panic(fmt.Sprintf("profiler: RegisterMiddleware called twice for %q", name))
+		}
+	}
+	middlewares = append(middlewares, MiddlewareSpec{Name: name, MiddlewareFactory: f})
+}
+
+// LookupMiddleware returns the middleware registered under name.
+func LookupMiddleware(name string) (MiddlewareSpec, bool) {
+	middlewareMu.RLock()
+	defer middlewareMu.RUnlock()
+	for _, spec := range middlewares {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return MiddlewareSpec{}, false
+}
+
+// MiddlewareSpecs returns all registered middleware in the order they were
+// registered.
+func MiddlewareSpecs() []MiddlewareSpec {
+	middlewareMu.RLock()
+	defer middlewareMu.RUnlock()
+	return append([]MiddlewareSpec(nil), middlewares...)
+}
+
+func init() {
+	RegisterMiddleware("drop", MiddlewareFactory{
+		Params:      "probability, seed",
+		Description: "Drops each allocation with the given probability before the profiler sees it.",
+		New: func(arg string, c Config) (Middleware, error) {
+			prob, err := strconv.ParseFloat(arg, 64)
+			if err != nil || prob < 0 || prob > 1 {
+				return nil, fmt.Errorf("drop: want probability between 0 and 1: %q", arg)
+			}
+			return DropEvents(prob, c.Rand), nil
+		},
+	})
+	RegisterMiddleware("budget", MiddlewareFactory{
+		Params:      "samples",
+		Description: "Stops profiling once the profiler has taken the given number of samples.",
+		New: func(arg string, c Config) (Middleware, error) {
+			n, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("budget: want number of samples: %q", arg)
+			}
+			return SampleBudget(n), nil
+		},
+	})
+	RegisterMiddleware("sizeclass", MiddlewareFactory{
+		Description: "Rounds allocation sizes up to the size classes of the Go runtime before the profiler sees them.",
+		New: func(arg string, c Config) (Middleware, error) {
+			if arg != "" {
+				return nil, fmt.Errorf("sizeclass: takes no argument: %q", arg)
+			}
+			return RoundSizes(), nil
+		},
+	})
+	RegisterMiddleware("ratechange", MiddlewareFactory{
+		Params:      "allocs:rate",
+		Description: "Changes the rate of the profiler after the given number of allocations, like a program setting runtime.MemProfileRate once it has started. All samples are scaled by the new rate, like runtime/pprof does.",
+		New: func(arg string, c Config) (Middleware, error) {
+			allocs, rate, _ := strings.Cut(arg, ":")
+			n, err := strconv.ParseInt(allocs, 10, 64)
+			r, rerr := strconv.Atoi(rate)
+			// A rate of 0 turns off the runtime's profiling instead,
+			// which the profilers don't model.
+			if err != nil || rerr != nil || n < 0 || r < 1 {
+				return nil, fmt.Errorf("ratechange: want allocs:rate with a positive rate: %q", arg)
+			}
+			return ChangeRate(n, r), nil
+		},
+	})
+	RegisterMiddleware("stage", MiddlewareFactory{
+		Description: "Publishes allocations to the profiler at the end of the GC cycle after theirs and frees at the end of theirs, like the Go runtime. Needs GC cycles, see -gc-every.",
+		New: func(arg string, c Config) (Middleware, error) {
+			if arg != "" {
+				return nil, fmt.Errorf("stage: takes no argument: %q", arg)
+			}
+			return Stage(), nil
+		},
+	})
+	RegisterMiddleware("frames", MiddlewareFactory{
+		Params:      "frames",
+		Description: "Truncates stacks to their innermost frames before the profiler sees them, like the Go runtime, which records at most 32 frames by default before Go 1.23 and 128 since.",
+		New: func(arg string, c Config) (Middleware, error) {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("frames: want number of frames: %q", arg)
+			}
+			return TruncateStacks(n), nil
+		},
+	})
+	RegisterMiddleware("inline", MiddlewareFactory{
+		Params:      "fraction",
+		Description: "Removes the callees of the given fraction of call sites from stacks before the profiler sees them, like inlined frames that aren't expanded. The same call sites are inlined for all profilers.",
+		New: func(arg string, c Config) (Middleware, error) {
+			fraction, err := strconv.ParseFloat(arg, 64)
+			if err != nil || fraction < 0 || fraction > 1 {
+				return nil, fmt.Errorf("inline: want fraction between 0 and 1: %q", arg)
+			}
+			return InlineFrames(fraction), nil
+		},
+	})
+	RegisterMiddleware("buffer", MiddlewareFactory{
+		Params:      "capacity:allocs",
+		Description: "Passes samples through a buffer of the given capacity that is drained by one sample every given number of allocations, dropping the samples that don't fit.",
+		New: func(arg string, c Config) (Middleware, error) {
+			capacity, every, _ := strings.Cut(arg, ":")
+			n, err := strconv.Atoi(capacity)
+			e, eerr := strconv.ParseInt(every, 10, 64)
+			if err != nil || eerr != nil || n < 0 || e < 1 {
+				return nil, fmt.Errorf("buffer: want capacity:allocs: %q", arg)
+			}
+			return BufferSamples(n, e), nil
+		},
+	})
+}
@@ -0,0 +1,90 @@
+package profiler
+
+import "math/rand"
+
+// Option configures a profiler created by one of the New functions.
+type Option func(*Config)
+
+// WithFormula sets the formula for scaling sampled values. The default is
+// ScaleHT.
+func WithFormula(f ScaleFormula) Option {
+	return func(c *Config) { c.Formula = f }
+}
+
+// WithRand sets the random number generator of stochastic profilers.
+func WithRand(r *rand.Rand) Option {
+	return func(c *Config) { c.Rand = r }
+}
+
+// WithExpFill sets the function filling a batch of standard exponential
+// variates for the sampling distances of the go profiler, e.g. the
+// ExpFloat64s method of an *rng.Wyrand. It should have a generator of its
+// own, see Go. By default the variates are drawn one at a time from the random
+// number generator.
+func WithExpFill(fill func(dst []float64)) Option {
+	return func(c *Config) { c.ExpFill = fill }
+}
+
+// WithSeed seeds a new random number generator for stochastic profilers.
+func WithSeed(seed int64) Option {
+	return WithRand(rand.New(rand.NewSource(seed)))
+}
+
+func newConfig(rate int, opts []Option) Config {
+	c := Config{Rate: rate}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.Rand == nil {
+		c.Rand = rand.New(rand.NewSource(1))
+	}
+	return c
+}
+
+// NewPerfect returns a profiler that records every allocation. Of the options
+// it only takes WithSizeHistograms.
+func NewPerfect(opts ...Option) *Perfect {
+	c := newConfig(0, opts)
+	return &Perfect{sizes: newSizeWeights(c.SizeHistograms, nil)}
+}
+
+// NewDotNet returns a profiler that samples one allocation every rate bytes.
+func NewDotNet(rate int, opts ...Option) *DotNet {
+	c := newConfig(rate, opts)
+	p := &DotNet{Formula: c.Formula, Estimator: c.Estimator, Rand: c.Rand, Rate: c.Rate, Naive: c.Naive, PerSample: c.PerSample, live: newLiveSet(c.InUse), certain: c.Init.certain(c.Warmup)}
+	p.nextSample = c.Init.distance(c.Rate, p.Rand.ExpFloat64)
+	p.sizes = newSizeWeights(c.SizeHistograms, p.factor)
+	return p
+}
+
+// WithNaive sets whether the dotnet profiler restarts its interval after each
+// sample, see DotNet.
+func WithNaive(naive bool) Option {
+	return func(c *Config) { c.Naive = naive }
+}
+
+// NewGo returns a profiler that samples allocations at exponentially
+// distributed byte intervals with a mean of rate. Without WithRand or WithSeed
+// the random number generator is seeded with 1.
+//
+// Estimators can be compared on the same samples by applying them to the raw
+// profile, see the example of ScaleFormula.Estimator.
+func NewGo(rate int, opts ...Option) *Go {
+	c := newConfig(rate, opts)
+	p := &Go{Formula: c.Formula, Estimator: c.Estimator, Rand: c.Rand, ExpFill: c.ExpFill, Rate: c.Rate, Buckets: c.Buckets, Remainder: c.Remainder, PerSample: c.PerSample, Uniform: c.Uniform, live: newLiveSet(c.InUse), certain: c.Init.certain(c.Warmup)}
+	p.nextSample = c.Init.distance(c.Rate, p.exp)
+	p.sizes = newSizeWeights(c.SizeHistograms, p.factor)
+	return p
+}
+
+// WithUniform sets whether the go profiler draws uniformly distributed
+// sampling distances like old Go runtimes, see Go.
+func WithUniform(uniform bool) Option {
+	return func(c *Config) { c.Uniform = uniform }
+}
+
+// WithRemainder sets whether the go profiler counts the bytes of a sampled
+// allocation beyond the threshold towards the next sample, see Go.
+func WithRemainder(remainder bool) Option {
+	return func(c *Config) { c.Remainder = remainder }
+}
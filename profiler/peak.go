@@ -0,0 +1,70 @@
+package profiler
+
+// TrackPeak returns a middleware that tracks the live heap of the allocations
+// and frees it sees, and calls snapshot with the profile of the profiler
+// whenever the live heap exceeds the one of the last snapshot by more than
+// 1/64. The last snapshot thus holds the in-use estimates at the peak of the
+// live heap, give or take 1/64. Profilers that see the same events take their
+// snapshots at the same events.
+//
+// The returned profilers implement interface{ AtPeak() bool }, which reports
+// whether the live heap is at least the one of the last snapshot, so that the
+// caller can take a final snapshot at the end of the workload in case the live
+// heap kept growing by less than 1/64 after the last one.
+func TrackPeak(snapshot func(Profile)) Middleware {
+	return func(p Profiler) Profiler { return &trackPeak{Wrapper: Wrapper{p}, snapshot: snapshot} }
+}
+
+type trackPeak struct {
+	Wrapper
+	snapshot   func(Profile)
+	live, peak int64
+}
+
+func (p *trackPeak) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+
+func (p *trackPeak) MallocID(size int, id StackID) {
+	MallocID(p.Profiler, size, id)
+	p.grow(int64(size))
+}
+
+func (p *trackPeak) MallocN(size int, count int64, id StackID) {
+	MallocN(p.Profiler, size, count, id)
+	p.grow(count * int64(size))
+}
+
+func (p *trackPeak) Free(size int, stack StackTrace) { p.FreeID(size, Intern(stack)) }
+
+func (p *trackPeak) FreeID(size int, id StackID) {
+	FreeID(p.Profiler, size, id)
+	p.live -= int64(size)
+}
+
+func (p *trackPeak) grow(bytes int64) {
+	if p.live += bytes; p.live > p.peak+p.peak/64 {
+		p.peak = p.live
+		p.snapshot(p.Profiler.Profile())
+	}
+}
+
+func (p *trackPeak) AtPeak() bool { return p.live >= p.peak }
+
+// Peak returns the values of p at the peak of the live heap as in-use values.
+func (p Profile) Peak() Profile {
+	peak := make(Profile, len(p))
+	for st, a := range p {
+		peak[st] = Alloc{InUseObjects: a.PeakObjects, InUseBytes: a.PeakBytes}
+	}
+	return peak
+}
+
+// SetPeak sets the peak values of p to the in-use values of peak, the profile
+// at the peak of the live heap.
+func (p *Profile) SetPeak(peak Profile) {
+	for st, a := range peak {
+		p.Add(st, Alloc{})
+		v := (*p)[st]
+		v.PeakObjects, v.PeakBytes = a.InUseObjects, a.InUseBytes
+		(*p)[st] = v
+	}
+}
@@ -0,0 +1,118 @@
+package profiler
+
+// PerPSuffix is appended to the name of a profiler keeping its state per P,
+// see PerP.
+const PerPSuffix = "-per-p"
+
+// ProcProfiler is implemented by profilers that keep state per P, i.e. per
+// processor running goroutines. Workloads running goroutines on several Ps
+// call SetProc before the allocations of each P.
+type ProcProfiler interface {
+	Profiler
+	SetProc(proc int)
+}
+
+// SetProc reports to p that the following events happen on the given P. It
+// does nothing if p doesn't implement ProcProfiler.
+func SetProc(p Profiler, proc int) {
+	if pp, ok := p.(ProcProfiler); ok {
+		pp.SetProc(proc)
+	}
+}
+
+func (w Wrapper) SetProc(proc int) { SetProc(w.Profiler, proc) }
+
+func (m Multi) SetProc(proc int) {
+	for _, p := range m {
+		SetProc(p, proc)
+	}
+}
+
+// PerP simulates a profiler per P, like the Go runtime keeps the sampling
+// state of each P in its mcache, and reports the profile of all of them. The
+// profilers of the Ps are created by New when a P is first used. All but the
+// first are resumed if they implement Merger, as the runtime draws the
+// distance to the first sample of each mcache. Their samples are merged
+// before they are estimated, or their estimates added up for profilers that
+// don't implement Merger.
+//
+// Frees go to the profiler of the current P, so workloads should free objects
+// on the P that allocated them.
+type PerP struct {
+	New func(proc int) Profiler
+
+	procs []IDProfiler
+	cur   IDProfiler
+	err   error
+}
+
+// NewPerP returns a profiler that simulates a profiler created by new per P.
+func NewPerP(new func(proc int) Profiler) *PerP {
+	p := &PerP{New: new}
+	p.SetProc(0)
+	return p
+}
+
+func (p *PerP) Name() string { return p.procs[0].Name() + PerPSuffix }
+
+func (p *PerP) SetProc(proc int) {
+	for len(p.procs) <= proc {
+		np := p.New(len(p.procs))
+		if m, ok := np.(Merger); ok && len(p.procs) > 0 {
+			m.Resume()
+		}
+		p.procs = append(p.procs, AsIDProfiler(np))
+	}
+	p.cur = p.procs[proc]
+}
+
+func (p *PerP) Malloc(size int, stack StackTrace)         { p.cur.Malloc(size, stack) }
+func (p *PerP) MallocID(size int, id StackID)             { p.cur.MallocID(size, id) }
+func (p *PerP) MallocN(size int, count int64, id StackID) { MallocN(p.cur, size, count, id) }
+func (p *PerP) Free(size int, stack StackTrace)           { Free(p.cur, size, stack) }
+func (p *PerP) FreeID(size int, id StackID)               { FreeID(p.cur, size, id) }
+
+func (p *PerP) GC() {
+	for _, pp := range p.procs {
+		GC(pp)
+	}
+}
+
+func (p *PerP) Samples() int64 {
+	var n int64
+	for _, pp := range p.procs {
+		n += (Wrapper{pp}).Samples()
+	}
+	return n
+}
+
+// Err returns the first error of the profilers of the Ps or of merging their
+// samples.
+func (p *PerP) Err() error {
+	for _, pp := range p.procs {
+		if err := (Wrapper{pp}).Err(); err != nil {
+			return err
+		}
+	}
+	return p.err
+}
+
+// Profile merges the samples of all Ps into a new profiler and returns its
+// profile, so that the profilers of the Ps keep their state, e.g. for taking
+// snapshots with TrackPeak.
+func (p *PerP) Profile() Profile {
+	m, ok := p.New(0).(Merger)
+	if _, isMerger := p.procs[0].(Merger); !ok || !isMerger {
+		var prof Profile
+		for _, pp := range p.procs {
+			prof.Merge(pp.Profile())
+		}
+		return prof
+	}
+	for _, pp := range p.procs {
+		if err := m.Merge(pp); err != nil && p.err == nil {
+			p.err = err
+		}
+	}
+	return m.Profile()
+}
@@ -0,0 +1,37 @@
+package profiler
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// TestPerPProfile checks that taking the profile of a PerP doesn't change the
+// samples it takes afterwards, as TrackPeak does while a workload runs.
+func TestPerPProfile(t *testing.T) {
+	id := Intern("perp")
+	for _, name := range []string{"dotnet", "go"} {
+		spec, _ := Lookup(name)
+		newPerP := func() *PerP {
+			return NewPerP(func(proc int) Profiler {
+				return spec.New(Config{Formula: ScaleHT, Rate: 1000, Rand: rand.New(rand.NewSource(int64(proc)))})
+			})
+		}
+		p, snapshotted := newPerP(), newPerP()
+		for i := 0; i < 1000; i++ {
+			for _, pp := range []*PerP{p, snapshotted} {
+				pp.SetProc(i % 3)
+				pp.MallocID(100+i%7, id)
+			}
+			if i%10 == 0 {
+				snapshotted.Profile()
+			}
+		}
+		if got, want := snapshotted.Profile(), p.Profile(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: with snapshots %v, without %v", name, got, want)
+		}
+		if err := snapshotted.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package profiler
+
+// WithPerSample sets whether sampling profilers scale each sample by the
+// inverse probability of sampling an object of its own size as it is taken,
+// rather than the samples of each stack by their average size when the
+// profile is estimated. For stacks allocating a single size both are the
+// same. A non-nil Estimator still gets the raw samples.
+func WithPerSample(perSample bool) Option {
+	return func(c *Config) { c.PerSample = perSample }
+}
+
+// sampleEstimates holds the scaled samples of each stack for profilers
+// scaling each sample by its own size.
+type sampleEstimates map[StackID]*sampleEstimate
+
+type sampleEstimate struct {
+	objects, bytes, freedObjects, freedBytes float64
+}
+
+func (s *sampleEstimates) get(id StackID) *sampleEstimate {
+	if *s == nil {
+		*s = sampleEstimates{}
+	}
+	e := (*s)[id]
+	if e == nil {
+		e = &sampleEstimate{}
+		(*s)[id] = e
+	}
+	return e
+}
+
+// add records n samples of size, each scaled by factor.
+func (s *sampleEstimates) add(id StackID, size int, n int64, factor float64) {
+	e := s.get(id)
+	e.objects += float64(n) * factor
+	e.bytes += float64(n) * float64(size) * factor
+}
+
+// free records the freeing of a sample of size that was scaled by factor.
+func (s *sampleEstimates) free(id StackID, size int, factor float64) {
+	e := s.get(id)
+	e.freedObjects += factor
+	e.freedBytes += float64(size) * factor
+}
+
+func (s *sampleEstimates) merge(o sampleEstimates) {
+	for id, oe := range o {
+		e := s.get(id)
+		e.objects += oe.objects
+		e.bytes += oe.bytes
+		e.freedObjects += oe.freedObjects
+		e.freedBytes += oe.freedBytes
+	}
+}
+
+// profile returns the estimates with the samples of raw.
+func (s sampleEstimates) profile(raw Profile) Profile {
+	p := sampled(raw)
+	for id, e := range s {
+		st := id.Stack()
+		a := p[st]
+		a.Objects, a.Bytes = int64(e.objects), int64(e.bytes)
+		a.InUseObjects, a.InUseBytes = int64(e.objects-e.freedObjects), int64(e.bytes-e.freedBytes)
+		p[st] = a
+	}
+	return p
+}
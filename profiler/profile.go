@@ -0,0 +1,120 @@
+package profiler
+
+import "strings"
+
+// Profile maps stack traces to the allocations attributed to them.
+type Profile map[StackTrace]Alloc
+
+func (p *Profile) Add(stack StackTrace, alloc Alloc) {
+	if *p == nil {
+		*p = Profile{}
+	}
+	update := (*p)[stack]
+	update.Objects += alloc.Objects
+	update.Bytes += alloc.Bytes
+	update.InUseObjects += alloc.InUseObjects
+	update.InUseBytes += alloc.InUseBytes
+	update.SampledObjects += alloc.SampledObjects
+	update.SampledBytes += alloc.SampledBytes
+	update.PeakObjects += alloc.PeakObjects
+	update.PeakBytes += alloc.PeakBytes
+	if len(alloc.Sizes) > 0 {
+		update.Sizes = update.Sizes.plus(alloc.Sizes)
+	}
+	(*p)[stack] = update
+}
+
+// Merge adds all allocations of other to p. Note that merging the estimates of
+// several profilers generally differs from estimating their merged samples,
+// which is what Merger does, because the estimate of a stack depends on the
+// average size of its samples.
+func (p *Profile) Merge(other Profile) {
+	for stack, alloc := range other {
+		p.Add(stack, alloc)
+	}
+}
+
+// Objects returns the total number of objects in the profile.
+func (p Profile) Objects() int64 {
+	var n int64
+	for _, v := range p {
+		n += v.Objects
+	}
+	return n
+}
+
+// SampledObjects returns the total number of samples the profile rests on.
+func (p Profile) SampledObjects() int64 {
+	var n int64
+	for _, v := range p {
+		n += v.SampledObjects
+	}
+	return n
+}
+
+func (p Profile) Copy() Profile {
+	copy := make(Profile, len(p))
+	for st, v := range p {
+		copy[st] = v
+	}
+	return copy
+}
+
+// Alloc counts allocated objects and bytes, and those of them that are still
+// in use, i.e. haven't been freed. The estimates of sampling profilers also
+// hold the raw samples they are based on, which equal the objects and bytes
+// for the perfect profiler and are zero for profilers that don't report them.
+type Alloc struct {
+	Objects      int64 `json:"objects"`
+	Bytes        int64 `json:"bytes"`
+	InUseObjects int64 `json:"inuseObjects"`
+	InUseBytes   int64 `json:"inuseBytes"`
+
+	SampledObjects int64 `json:"sampledObjects,omitempty"`
+	SampledBytes   int64 `json:"sampledBytes,omitempty"`
+
+	// PeakObjects and PeakBytes are the in-use values at the peak of the
+	// live heap, see TrackPeak.
+	PeakObjects int64 `json:"peakObjects,omitempty"`
+	PeakBytes   int64 `json:"peakBytes,omitempty"`
+
+	// Sizes is the histogram of the objects by size, see WithSizeHistograms.
+	Sizes SizeHistogram `json:"sizes,omitempty"`
+}
+
+// StackTrace identifies the call site of an allocation. It holds the frames of
+// the call stack separated by semicolons, starting at the root, like the
+// folded stacks of flame graph tools, so a stack without semicolons is a
+// single frame.
+type StackTrace string
+
+const frameSep = ";"
+
+// NewStackTrace returns the stack trace of the given frames, starting at the
+// root.
+func NewStackTrace(frames ...string) StackTrace {
+	return StackTrace(strings.Join(frames, frameSep))
+}
+
+// Frames returns the frames of s, starting at the root.
+func (s StackTrace) Frames() []string { return strings.Split(string(s), frameSep) }
+
+// Depth returns the number of frames of s.
+func (s StackTrace) Depth() int { return strings.Count(string(s), frameSep) + 1 }
+
+// Truncate returns the innermost n frames of s, like the Go runtime, which
+// only records a fixed number of frames of each stack. Stacks only differing
+// in the frames beyond n are truncated to the same stack.
+func (s StackTrace) Truncate(n int) StackTrace {
+	if n <= 0 {
+		return s
+	}
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] == frameSep[0] {
+			if n--; n == 0 {
+				return s[i+1:]
+			}
+		}
+	}
+	return s
+}
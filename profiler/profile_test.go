@@ -0,0 +1,11 @@
+package profiler
+
+import "testing"
+
+func BenchmarkProfileAdd(b *testing.B) {
+	stacks := []StackTrace{"main;a", "main;b"}
+	var p Profile
+	for i := 0; i < b.N; i++ {
+		p.Add(stacks[i%2], Alloc{Objects: 1, Bytes: 16})
+	}
+}
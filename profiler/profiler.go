@@ -0,0 +1,422 @@
+// Package profiler implements simulated allocation profilers.
+package profiler
+
+import (
+	"math/rand"
+)
+
+// Profiler is a simulated allocation profiler.
+type Profiler interface {
+	Name() string
+	Malloc(size int, stack StackTrace)
+	Profile() Profile
+}
+
+// Perfect records every allocation and free and reports the results.
+type Perfect struct {
+	prof  denseCounts
+	freed denseCounts
+	sizes *sizeWeights
+}
+
+func (p *Perfect) Name() string { return "perfect" }
+
+func (p *Perfect) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+func (p *Perfect) MallocID(size int, id StackID) {
+	p.prof.add(id, 1, int64(size))
+	p.sizes.add(id, size, 1)
+}
+func (p *Perfect) Profile() Profile {
+	return p.sizes.apply(sampled(inUse(p.prof.profile(), p.freed.profile())))
+}
+func (p *Perfect) Samples() int64 { return p.prof.objects() }
+
+// DotNet records one allocation every Rate bytes. By default the resulting
+// profile is scaled by 1/(size/rate) to estimate the true allocations. A
+// non-nil Estimator replaces the Formula. Rand picks the objects that are
+// freed, see WithInUse.
+//
+// DotNet counts bytes like a real byte counter: the bytes of a sampled
+// allocation beyond the sampling threshold count towards the next one, so an
+// allocation of at least Rate bytes is always sampled and allocations
+// following it are sampled as if it had been split into intervals of Rate
+// bytes. Its probability of sampling an allocation is thus exactly
+// min(1, size/rate). Naive instead restarts the interval after each sample,
+// which drops those bytes, as earlier versions did. PerSample scales each
+// sample by its own size, see WithPerSample.
+type DotNet struct {
+	Formula   ScaleFormula
+	Estimator Estimator
+	Rand      *rand.Rand
+	Rate      int
+	Naive     bool
+	PerSample bool
+
+	nextSample int
+	prof       counts
+	live       *liveSet
+	freed      counts
+	est        sampleEstimates
+	// certain is set until the certain first sample has been taken, which
+	// is recorded in warm, see WithWarmup.
+	certain bool
+	warm    counts
+	// sizes is nil unless histograms are reported, see
+	// WithSizeHistograms.
+	sizes *sizeWeights
+}
+
+func (p *DotNet) Name() string {
+	name := "dotnet"
+	if p.Naive {
+		name += "-naive"
+	}
+	if p.PerSample {
+		name += "-sample"
+	}
+	return name
+}
+
+func (p *DotNet) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+func (p *DotNet) MallocID(size int, id StackID) {
+	if size < p.nextSample {
+		p.nextSample -= size
+		p.live.add(id, size, 1, 0)
+	} else {
+		p.record(id, size)
+		if p.Naive || p.Rate <= 0 {
+			p.nextSample = p.Rate
+		} else {
+			p.nextSample = p.Rate - (size-p.nextSample)%p.Rate
+		}
+	}
+}
+
+// warmUp records the first of samples among count allocations of size at id
+// in warm if it is certain, and returns the remaining samples and count.
+func (p *DotNet) warmUp(id StackID, size int, samples, count int64) (int64, int64) {
+	if !p.certain || samples == 0 {
+		return samples, count
+	}
+	p.certain = false
+	p.warm.add(id, 1, int64(size))
+	p.live.add(id, size, 1, 0)
+	p.sizes.addWeighted(id, size, 1, 1)
+	return samples - 1, count - 1
+}
+
+// record records a sample of size at id.
+func (p *DotNet) record(id StackID, size int) {
+	if samples, _ := p.warmUp(id, size, 1, 1); samples == 0 {
+		return
+	}
+	p.prof.add(id, 1, int64(size))
+	p.live.add(id, size, 1, 1)
+	p.sizes.add(id, size, 1)
+	if p.PerSample {
+		p.est.add(id, size, 1, p.factor(size))
+	}
+}
+
+func (p *DotNet) Samples() int64 { return p.prof.objects() + p.warm.objects() }
+func (p *DotNet) Raw() Profile   { return inUse(p.prof.profile(), p.freed.profile()) }
+func (p *DotNet) Profile() Profile {
+	if p.PerSample && p.Estimator == nil {
+		return p.sizes.apply(withWarm(p.est.profile(p.Raw()), &p.warm))
+	}
+	return p.sizes.apply(withWarm(estimate(p.Estimator, p.Formula, ScaleLegacy, p.Raw(), p.Rate), &p.warm))
+}
+
+// factor returns the scale of a sample of size, see PerSample.
+func (p *DotNet) factor(size int) float64 {
+	return p.Formula.resolve(ScaleLegacy).factor(float64(size), p.Rate)
+}
+
+// Go records an allocation and then draws a random sampling distance in bytes
+// for the next allocation from the exponential distribution with a mean of
+// Rate. By default the resulting profile is scaled by 1 / (1 - e^(-size/rate))
+// to estimate the true allocations. A non-nil Estimator replaces the Formula.
+// By default the sampling distances are drawn from Rand.ExpFloat64 one at a
+// time. If ExpFill is set, they are drawn in batches from it instead, which
+// amortizes the cost of calling it. This gives the same results as drawing
+// them one at a time as long as ExpFill doesn't share its generator with
+// Rand.
+//
+// The Go runtime keeps the samples of each stack and size in a separate
+// bucket, which is scaled by its own size. By default Go keeps the samples of
+// each stack together and scales them by their average size instead, which
+// differs for stacks allocating several sizes. Buckets makes it behave like
+// the runtime.
+//
+// Like the runtime, Go starts the distance to the next sample at the end of
+// a sampled allocation. With Remainder it instead starts at the threshold
+// within it, so the rest of the allocation counts towards the next sample,
+// as in a Poisson process over all allocated bytes. As the distances are
+// memoryless, both sample each allocation with probability 1 - e^(-size/rate),
+// except that distances are truncated to whole bytes: without Remainder a
+// distance of zero samples the allocation right after a sample regardless of
+// its size, so it is sampled as if it were a byte larger. This is noticeable
+// at small rates, e.g. +2% samples of 16 byte allocations at a rate of 16.
+//
+// Like runtime.MemProfileRate, a Rate of 1 samples every allocation without
+// drawing distances.
+//
+// PerSample scales each sample by its own size as it is taken, see
+// WithPerSample. This is the same as Buckets, except that the estimates of
+// the buckets aren't truncated to whole numbers before they are added up. It
+// takes precedence over Buckets.
+//
+// Uniform reproduces the sampler of the Go runtime before it switched to
+// exponentially distributed distances: allocations of at least Rate bytes
+// are always sampled and keep the distance, and after sampling a smaller
+// one the distance is drawn uniformly from [0, 2*Rate), dropping the bytes
+// of the allocation beyond the threshold, see mallocgc in Go 1.0. Its
+// sampling probability isn't 1 - e^(-size/rate), so scaling its samples by
+// the inverse of that is biased.
+type Go struct {
+	Formula   ScaleFormula
+	Estimator Estimator
+	Rand      *rand.Rand
+	ExpFill   func(dst []float64)
+	Rate      int
+	Buckets   bool
+	Remainder bool
+	PerSample bool
+	Uniform   bool
+
+	nextSample int
+	// exps holds the standard exponential variates for the upcoming
+	// sampling distances, of which the last left haven't been used yet.
+	exps  [goExpBatch]float64
+	left  int
+	prof  counts
+	live  *liveSet
+	freed counts
+	sized sizedCounts
+	est   sampleEstimates
+	// certain, warm and sizes are like those of DotNet.
+	certain bool
+	warm    counts
+	sizes   *sizeWeights
+}
+
+const goExpBatch = 256
+
+func (p *Go) Name() string {
+	name := "go"
+	if p.Buckets {
+		name += "-bucket"
+	}
+	if p.Remainder {
+		name += "-remainder"
+	}
+	if p.PerSample {
+		name += "-sample"
+	}
+	if p.Uniform {
+		name += "-uniform"
+	}
+	return name
+}
+
+func (p *Go) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+func (p *Go) MallocID(size int, id StackID) {
+	if p.Rate != 1 && size < p.nextSample && !(p.Uniform && size >= p.Rate) {
+		p.nextSample -= size
+		p.live.add(id, size, 1, 0)
+	} else {
+		p.record(id, size)
+		if p.Rate == 1 {
+			// The runtime doesn't look at the distance at this rate.
+			p.nextSample = 0
+			return
+		} else if p.Remainder {
+			p.consume(size)
+			return
+		} else if p.Uniform {
+			if size < p.Rate {
+				p.nextSample = p.uniform()
+			}
+			return
+		}
+		p.nextSample = int(float64(p.Rate) * p.exp())
+		// code above produces the same result as:
+		//p.nextSample = int(-math.Log(1-p.Rand.Float64()) / (1 / float64(p.Rate)))
+	}
+}
+
+// record records a sample of size at id.
+func (p *Go) record(id StackID, size int) {
+	if p.certain {
+		p.certain = false
+		p.warm.add(id, 1, int64(size))
+		p.live.add(id, size, 1, 0)
+		p.sizes.addWeighted(id, size, 1, 1)
+		return
+	}
+	p.prof.add(id, 1, int64(size))
+	p.live.add(id, size, 1, 1)
+	p.sizes.add(id, size, 1)
+	if p.Buckets {
+		p.sized.add(id, size, 1)
+	}
+	if p.PerSample {
+		p.est.add(id, size, 1, p.factor(size))
+	}
+}
+
+// consume draws sampling distances starting at the threshold within the
+// sampled allocation of size until one ends beyond it, so that the rest of the
+// allocation counts towards the next sample.
+func (p *Go) consume(size int) {
+	next := p.nextSample - size
+	for next <= 0 && p.Rate > 0 {
+		next += int(float64(p.Rate) * p.exp())
+	}
+	p.nextSample = next
+}
+
+// uniform returns a distance drawn uniformly from [0, 2*Rate), see Uniform.
+func (p *Go) uniform() int { return int(p.Rand.Int63n(2 * int64(p.Rate))) }
+
+func (p *Go) exp() float64 {
+	if p.ExpFill == nil {
+		return p.Rand.ExpFloat64()
+	}
+	if p.left == 0 {
+		p.ExpFill(p.exps[:])
+		p.left = len(p.exps)
+	}
+	x := p.exps[len(p.exps)-p.left]
+	p.left--
+	return x
+}
+
+func (p *Go) Samples() int64 { return p.prof.objects() + p.warm.objects() }
+func (p *Go) Raw() Profile   { return inUse(p.prof.profile(), p.freed.profile()) }
+func (p *Go) Profile() Profile {
+	var prof Profile
+	if p.PerSample && p.Estimator == nil {
+		prof = p.est.profile(p.Raw())
+	} else if p.Buckets {
+		prof = p.sized.estimate(p.Estimator, p.Formula, ScaleGo, p.Rate)
+	} else {
+		prof = estimate(p.Estimator, p.Formula, ScaleGo, p.Raw(), p.Rate)
+	}
+	return p.sizes.apply(withWarm(prof, &p.warm))
+}
+
+// factor returns the scale of a sample of size, see PerSample.
+func (p *Go) factor(size int) float64 {
+	return p.Formula.resolve(ScaleGo).factor(float64(size), p.Rate)
+}
+
+// Config holds the parameters for creating a profiler.
+type Config struct {
+	Formula   ScaleFormula
+	Estimator Estimator
+	Rate      int
+	Rand      *rand.Rand
+	ExpFill   func(dst []float64)
+	// Naive makes the dotnet profiler drop the bytes beyond the sampling
+	// threshold, see DotNet.
+	Naive bool
+	// Remainder makes the go profiler count the bytes of a sampled
+	// allocation beyond the threshold towards the next sample, see Go.
+	Remainder bool
+	// Buckets makes the go profiler estimate each stack and size separately,
+	// see Go.
+	Buckets bool
+	// Uniform makes the go profiler draw uniformly distributed sampling
+	// distances like old Go runtimes, see Go.
+	Uniform bool
+	// Init selects the distance to the first sample of sampling profilers,
+	// see WithInit.
+	Init SampleInit
+	// Warmup makes sampling profilers count a certain first sample as is,
+	// see WithWarmup.
+	Warmup bool
+	// PerSample makes sampling profilers scale each sample by its own size,
+	// see WithPerSample.
+	PerSample bool
+	// InUse enables the tracking of frees by sampling profilers, see
+	// WithInUse.
+	InUse bool
+	// SizeHistograms makes profilers report a histogram of the sizes of
+	// each stack, see WithSizeHistograms.
+	SizeHistograms bool
+}
+
+func init() {
+	Register("perfect", Factory{
+		Version:     1,
+		Description: "Records every allocation.",
+		New:         func(c Config) Profiler { return NewPerfect(WithSizeHistograms(c.SizeHistograms)) },
+	})
+	Register("dotnet", Factory{
+		Version:     2,
+		Params:      "rate, scale-formula",
+		Description: "Samples one allocation every rate bytes.",
+		New: func(c Config) Profiler {
+			return NewDotNet(c.Rate, WithFormula(c.Formula), WithEstimator(c.Estimator), WithRand(c.Rand), WithInUse(c.InUse), WithInit(c.Init), WithWarmup(c.Warmup), WithSizeHistograms(c.SizeHistograms))
+		},
+	})
+	Register("dotnet-naive", Factory{
+		Version:     1,
+		Params:      "rate, scale-formula",
+		Description: "Like dotnet, but restarts the interval after each sample, dropping the remaining bytes of the sampled allocation.",
+		Biased:      true,
+		New: func(c Config) Profiler {
+			return NewDotNet(c.Rate, WithFormula(c.Formula), WithEstimator(c.Estimator), WithRand(c.Rand), WithInUse(c.InUse), WithInit(c.Init), WithWarmup(c.Warmup), WithSizeHistograms(c.SizeHistograms), WithNaive(true))
+		},
+	})
+	Register("dotnet-sample", Factory{
+		Version:     1,
+		Params:      "rate, scale-formula",
+		Description: "Like dotnet, but scales each sample by its own size rather than the average size of its stack.",
+		New: func(c Config) Profiler {
+			return NewDotNet(c.Rate, WithFormula(c.Formula), WithEstimator(c.Estimator), WithRand(c.Rand), WithInUse(c.InUse), WithInit(c.Init), WithWarmup(c.Warmup), WithSizeHistograms(c.SizeHistograms), WithPerSample(true))
+		},
+	})
+	Register("go", Factory{
+		Version:     5,
+		Params:      "rate, scale-formula, seed",
+		Description: "Samples allocations at exponentially distributed byte intervals with a mean of rate.",
+		New: func(c Config) Profiler {
+			return NewGo(c.Rate, WithFormula(c.Formula), WithEstimator(c.Estimator), WithRand(c.Rand), WithExpFill(c.ExpFill), WithInUse(c.InUse), WithInit(c.Init), WithWarmup(c.Warmup), WithSizeHistograms(c.SizeHistograms))
+		},
+	})
+	Register("go-bucket", Factory{
+		Version:     2,
+		Params:      "rate, scale-formula, seed",
+		Description: "Like go, but estimates the samples of each stack and size separately like the buckets of the Go runtime.",
+		New: func(c Config) Profiler {
+			return NewGo(c.Rate, WithFormula(c.Formula), WithEstimator(c.Estimator), WithRand(c.Rand), WithExpFill(c.ExpFill), WithInUse(c.InUse), WithInit(c.Init), WithWarmup(c.Warmup), WithSizeHistograms(c.SizeHistograms), WithBuckets(true))
+		},
+	})
+	Register("go-remainder", Factory{
+		Version:     2,
+		Params:      "rate, scale-formula, seed",
+		Description: "Like go, but counts the bytes of a sampled allocation beyond the threshold towards the next sample.",
+		New: func(c Config) Profiler {
+			return NewGo(c.Rate, WithFormula(c.Formula), WithEstimator(c.Estimator), WithRand(c.Rand), WithExpFill(c.ExpFill), WithInUse(c.InUse), WithInit(c.Init), WithWarmup(c.Warmup), WithSizeHistograms(c.SizeHistograms), WithRemainder(true))
+		},
+	})
+	Register("go-uniform", Factory{
+		Version:     1,
+		Params:      "rate, scale-formula, seed",
+		Description: "Like go, but with the sampler of old Go runtimes, which always sampled allocations of at least rate bytes and drew uniformly distributed distances for smaller ones.",
+		Biased:      true,
+		New: func(c Config) Profiler {
+			return NewGo(c.Rate, WithFormula(c.Formula), WithEstimator(c.Estimator), WithRand(c.Rand), WithExpFill(c.ExpFill), WithInUse(c.InUse), WithInit(c.Init), WithWarmup(c.Warmup), WithSizeHistograms(c.SizeHistograms), WithUniform(true))
+		},
+	})
+	Register("go-sample", Factory{
+		Version:     1,
+		Params:      "rate, scale-formula, seed",
+		Description: "Like go, but scales each sample by its own size rather than the average size of its stack.",
+		New: func(c Config) Profiler {
+			return NewGo(c.Rate, WithFormula(c.Formula), WithEstimator(c.Estimator), WithRand(c.Rand), WithExpFill(c.ExpFill), WithInUse(c.InUse), WithInit(c.Init), WithWarmup(c.Warmup), WithSizeHistograms(c.SizeHistograms), WithPerSample(true))
+		},
+	})
+}
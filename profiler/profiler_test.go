@@ -0,0 +1,20 @@
+package profiler
+
+import "testing"
+
+func BenchmarkPerfectMallocID(b *testing.B) {
+	ids := []StackID{Intern("main;a"), Intern("main;b")}
+	p := NewPerfect()
+	for i := 0; i < b.N; i++ {
+		p.MallocID(16, ids[i%2])
+	}
+}
+
+// BenchmarkPerfectMalloc includes interning the stack.
+func BenchmarkPerfectMalloc(b *testing.B) {
+	stacks := []StackTrace{"main;a", "main;b"}
+	p := NewPerfect()
+	for i := 0; i < b.N; i++ {
+		p.Malloc(16, stacks[i%2])
+	}
+}
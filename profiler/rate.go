@@ -0,0 +1,70 @@
+package profiler
+
+// RateSetter is implemented by profilers whose rate can be changed while they
+// are running, like runtime.MemProfileRate.
+type RateSetter interface {
+	Profiler
+	SetRate(rate int)
+}
+
+// SetRate changes the rate of p. It does nothing if p doesn't implement
+// RateSetter.
+func SetRate(p Profiler, rate int) {
+	if rs, ok := p.(RateSetter); ok {
+		rs.SetRate(rate)
+	}
+}
+
+func (w Wrapper) SetRate(rate int) { SetRate(w.Profiler, rate) }
+
+// SetRate keeps the current interval, so the new rate applies after the next
+// sample.
+func (p *DotNet) SetRate(rate int) { p.Rate = rate }
+
+// SetRate keeps the distance to the next sample, which was drawn for the old
+// rate, like the runtime does when MemProfileRate changes. The samples taken
+// before are scaled by the new rate, as runtime/pprof only knows the current
+// rate.
+func (p *Go) SetRate(rate int) { p.Rate = rate }
+
+// ChangeRate returns a middleware that changes the rate of the profiler to
+// rate after it has seen after allocations, e.g. to model a program that sets
+// runtime.MemProfileRate once it has started.
+func ChangeRate(after int64, rate int) Middleware {
+	return func(p Profiler) Profiler { return &changeRate{Wrapper: Wrapper{p}, left: after, rate: rate} }
+}
+
+type changeRate struct {
+	Wrapper
+	// left counts the allocations before the change. It is negative once
+	// the rate has changed.
+	left int64
+	rate int
+}
+
+func (p *changeRate) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+
+func (p *changeRate) MallocID(size int, id StackID) {
+	p.advance(1)
+	MallocID(p.Profiler, size, id)
+}
+
+func (p *changeRate) MallocN(size int, count int64, id StackID) {
+	if p.left > 0 && p.left < count {
+		before := p.left
+		p.advance(before)
+		MallocN(p.Profiler, size, before, id)
+		count -= before
+	}
+	p.advance(count)
+	MallocN(p.Profiler, size, count, id)
+}
+
+// advance changes the rate if no allocations before the change are left and
+// counts count more allocations, which must not pass the change.
+func (p *changeRate) advance(count int64) {
+	if p.left == 0 {
+		SetRate(p.Profiler, p.rate)
+	}
+	p.left -= count
+}
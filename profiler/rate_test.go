@@ -0,0 +1,48 @@
+package profiler
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestRateChangeArg(t *testing.T) {
+	spec, _ := LookupMiddleware("ratechange")
+	for _, tt := range []struct {
+		arg string
+		ok  bool
+	}{
+		{"0:1", true},
+		{"100:512", true},
+		{"100:0", false},
+		{"100:-1", false},
+		{"-1:512", false},
+		{"100", false},
+	} {
+		_, err := spec.New(tt.arg, Config{})
+		if (err == nil) != tt.ok {
+			t.Errorf("ratechange=%s: got error %v, want ok %v", tt.arg, err, tt.ok)
+		}
+	}
+}
+
+// TestChangeRate checks that the rate changes after exactly the given number
+// of allocations, also when they are passed in a batch.
+func TestChangeRate(t *testing.T) {
+	spec, _ := Lookup("go")
+	id := Intern("alloc")
+	for _, batch := range []bool{false, true} {
+		p := ChangeRate(100, 1)(spec.New(Config{Formula: ScaleHT, Rate: 1 << 40, Rand: rand.New(rand.NewSource(1))}))
+		if batch {
+			MallocN(p, 16, 150, id)
+		} else {
+			for i := 0; i < 150; i++ {
+				MallocID(p, 16, id)
+			}
+		}
+		// The first allocation is always sampled, and at a rate of 1
+		// byte, so is every allocation after the change.
+		if got := p.(interface{ Samples() int64 }).Samples(); got != 51 {
+			t.Errorf("batch=%v: %d samples, want 51", batch, got)
+		}
+	}
+}
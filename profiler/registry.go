@@ -0,0 +1,64 @@
+package profiler
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Factory describes how to create a registered profiler. Version must be
+// incremented whenever the profiler's behavior changes in order to invalidate
+// cached results. Profilers whose behavior isn't captured by their version,
+// e.g. because they are implemented externally, should set NoCache.
+type Factory struct {
+	Version     int
+	Params      string
+	Description string
+	NoCache     bool
+	// Biased marks profilers that are known to be biased, e.g. to show the
+	// effect of a flaw. Self tests skip them.
+	Biased bool
+	New    func(Config) Profiler
+}
+
+// Spec is a registered profiler.
+type Spec struct {
+	Name string
+	Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Spec
+)
+
+// Register makes a profiler available under name, e.g. for selection on the
+// command line. It panics if name is already registered.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, spec := range registry {
+		if spec.Name == name {
+			panic(fmt.Sprintf("profiler: Register called twice for %q", name))
+		}
+	}
+	registry = append(registry, Spec{Name: name, Factory: f})
+}
+
+// Lookup returns the profiler registered under name.
+func Lookup(name string) (Spec, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, spec := range registry {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return Spec{}, false
+}
+
+// Specs returns all registered profilers in the order they were registered.
+func Specs() []Spec {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]Spec(nil), registry...)
+}
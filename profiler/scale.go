@@ -0,0 +1,129 @@
+package profiler
+
+import (
+	"fmt"
+	"math"
+)
+
+// ScaleFormula selects how a sampled profile is scaled to estimate the true
+// allocations.
+type ScaleFormula string
+
+const (
+	// ScaleHT scales by the inverse of the profiler's own probability of
+	// sampling an allocation of the stack's average size, i.e. it is the
+	// Horvitz-Thompson estimator. It is the default.
+	ScaleHT ScaleFormula = "ht"
+	// ScaleGo scales by 1 / (1 - e^(-size/rate)), the inverse sampling
+	// probability of the Go profiler, or not at all for a rate of 1, at
+	// which it samples every allocation.
+	ScaleGo ScaleFormula = "go"
+	// ScaleLegacy scales by rate/size, or 1 for sizes above rate, the inverse
+	// sampling probability of the dotnet profiler.
+	ScaleLegacy ScaleFormula = "legacy"
+	// ScaleNone reports the sampled values as is.
+	ScaleNone ScaleFormula = "none"
+	// ScalePprof is ScaleGo as implemented by scaleHeapSample in
+	// runtime/pprof, including its handling of rates of at most 1 and
+	// samples without bytes, so that its results can be checked against
+	// ScaleGo. The runtime applies it to each stack and size separately,
+	// like the go-bucket profiler.
+	ScalePprof ScaleFormula = "pprof"
+)
+
+// ScaleFormulas lists all scale formulas.
+var ScaleFormulas = []ScaleFormula{ScaleHT, ScaleGo, ScaleLegacy, ScaleNone, ScalePprof}
+
+// ParseScaleFormula returns the scale formula with the given name.
+func ParseScaleFormula(s string) (ScaleFormula, error) {
+	for _, v := range ScaleFormulas {
+		if string(v) == s {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("unknown scale formula: %q", s)
+}
+
+// Scale returns p scaled according to f for the given sampling rate. ht is
+// the formula that implements ScaleHT for the calling profiler.
+func (f ScaleFormula) Scale(p Profile, rate int, ht ScaleFormula) Profile {
+	if f.resolve(ht) == ScaleNone {
+		return p
+	}
+	return f.scale(p.Copy(), rate, ht)
+}
+
+func (f ScaleFormula) resolve(ht ScaleFormula) ScaleFormula {
+	if f == ScaleHT || f == "" {
+		return ht
+	}
+	return f
+}
+
+// scale is like Scale, but modifies p in place.
+func (f ScaleFormula) scale(p Profile, rate int, ht ScaleFormula) Profile {
+	f = f.resolve(ht)
+	if f == ScaleNone {
+		return p
+	}
+	for st, v := range p {
+		v.Objects, v.Bytes = f.scalePair(v.Objects, v.Bytes, rate)
+		// The in-use values are scaled by the average size of the live
+		// samples, which differs from that of all samples if objects of
+		// different sizes are freed.
+		v.InUseObjects, v.InUseBytes = f.scalePair(v.InUseObjects, v.InUseBytes, rate)
+		p[st] = v
+	}
+	return p
+}
+
+// scalePair scales objects and bytes sampled at a stack by the inverse
+// probability of sampling an object of their average size.
+func (f ScaleFormula) scalePair(objects, bytes int64, rate int) (int64, int64) {
+	if f == ScalePprof {
+		return scaleHeapSample(objects, bytes, int64(rate))
+	} else if objects == 0 {
+		return 0, 0
+	}
+	scale := f.factor(float64(bytes)/float64(objects), rate)
+	return int64(float64(objects) * scale), int64(float64(bytes) * scale)
+}
+
+// factor returns the inverse probability of sampling an object of the given
+// size according to f, which must be resolved.
+func (f ScaleFormula) factor(size float64, rate int) float64 {
+	switch f {
+	case ScaleGo:
+		if rate != 1 {
+			return 1 / (1 - math.Exp(-size/float64(rate)))
+		}
+	case ScaleLegacy:
+		if int(size) <= rate {
+			return 1 / (size / float64(rate))
+		}
+	case ScalePprof:
+		if rate > 1 && size > 0 {
+			return 1 / (1 - math.Exp(-size/float64(rate)))
+		}
+	}
+	return 1
+}
+
+// scaleHeapSample is a copy of the function of the same name in
+// runtime/pprof/protomem.go.
+func scaleHeapSample(count, size, rate int64) (int64, int64) {
+	if count == 0 || size == 0 {
+		return 0, 0
+	}
+
+	if rate <= 1 {
+		// if rate==1 all samples were collected so no adjustment is needed.
+		// if rate<1 treat as unknown and skip scaling.
+		return count, size
+	}
+
+	avgSize := float64(size) / float64(count)
+	scale := 1 / (1 - math.Exp(-avgSize/float64(rate)))
+
+	return int64(float64(count) * scale), int64(float64(size) * scale)
+}
@@ -0,0 +1,72 @@
+package profiler
+
+// sizeClasses are the object sizes of the small size classes of the Go
+// runtime, see runtime/sizeclasses.go.
+var sizeClasses = []int{
+	0, 8, 16, 24, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224,
+	240, 256, 288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768, 896,
+	1024, 1152, 1280, 1408, 1536, 1792, 2048, 2304, 2688, 3072, 3200, 3456,
+	4096, 4864, 5376, 6144, 6528, 6784, 6912, 8192, 9472, 9728, 10240, 10880,
+	12288, 13568, 14336, 16384, 18432, 19072, 20480, 21760, 24576, 27264,
+	28672, 32768,
+}
+
+const (
+	maxSmallSize = 32768
+	pageSize     = 8192
+)
+
+// sizeToClass8 and sizeToClass128 map sizes to their size class in steps of 8
+// bytes up to 1024 and of 128 bytes above, like the runtime's tables.
+var (
+	sizeToClass8   [1024/8 + 1]uint8
+	sizeToClass128 [(maxSmallSize-1024)/128 + 1]uint8
+)
+
+func init() {
+	class := 0
+	for i := range sizeToClass8 {
+		for sizeClasses[class] < i*8 {
+			class++
+		}
+		sizeToClass8[i] = uint8(class)
+	}
+	for i := range sizeToClass128 {
+		for sizeClasses[class] < 1024+i*128 {
+			class++
+		}
+		sizeToClass128[i] = uint8(class)
+	}
+}
+
+// SizeClass returns the number of bytes the Go runtime allocates for an object
+// of size bytes: the size of its size class for small objects, and size
+// rounded up to whole pages for large ones.
+func SizeClass(size int) int {
+	switch {
+	case size <= 1024:
+		return sizeClasses[sizeToClass8[(size+7)/8]]
+	case size <= maxSmallSize:
+		return sizeClasses[sizeToClass128[(size-1024+127)/128]]
+	}
+	return (size + pageSize - 1) / pageSize * pageSize
+}
+
+// RoundSizes returns a middleware that rounds the size of each allocation and
+// free up to its SizeClass before the profiler sees it, as the sampler of the
+// Go runtime only sees rounded sizes.
+func RoundSizes() Middleware {
+	return func(p Profiler) Profiler { return roundSizes{Wrapper{p}} }
+}
+
+type roundSizes struct {
+	Wrapper
+}
+
+func (p roundSizes) Malloc(size int, stack StackTrace) { p.Profiler.Malloc(SizeClass(size), stack) }
+func (p roundSizes) MallocID(size int, id StackID)     { MallocID(p.Profiler, SizeClass(size), id) }
+func (p roundSizes) MallocN(size int, count int64, id StackID) {
+	MallocN(p.Profiler, SizeClass(size), count, id)
+}
+func (p roundSizes) Free(size int, stack StackTrace) { Free(p.Profiler, SizeClass(size), stack) }
+func (p roundSizes) FreeID(size int, id StackID)     { FreeID(p.Profiler, SizeClass(size), id) }
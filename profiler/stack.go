@@ -0,0 +1,195 @@
+package profiler
+
+import "sync"
+
+// StackID is an interned StackTrace. Profilers store their samples in slices
+// indexed by StackID to avoid hashing stack traces for every allocation.
+type StackID uint32
+
+var (
+	stacksMu sync.RWMutex
+	stackIDs = map[StackTrace]StackID{}
+	stacks   []StackTrace
+)
+
+// Intern returns the ID of stack, assigning a new one if needed. IDs are never
+// released.
+func Intern(stack StackTrace) StackID {
+	stacksMu.RLock()
+	id, ok := stackIDs[stack]
+	stacksMu.RUnlock()
+	if ok {
+		return id
+	}
+	stacksMu.Lock()
+	defer stacksMu.Unlock()
+	if id, ok := stackIDs[stack]; ok {
+		return id
+	}
+	id = StackID(len(stacks))
+	stackIDs[stack] = id
+	stacks = append(stacks, stack)
+	return id
+}
+
+// Stack returns the stack trace interned as id.
+func (id StackID) Stack() StackTrace {
+	stacksMu.RLock()
+	defer stacksMu.RUnlock()
+	return stacks[id]
+}
+
+// IDProfiler is implemented by profilers that accept interned stacks, which is
+// faster than Malloc for workloads that intern their stacks up front.
+type IDProfiler interface {
+	Profiler
+	MallocID(size int, id StackID)
+}
+
+// MallocID reports an allocation at the interned stack id to p, falling back
+// to Malloc if p doesn't implement IDProfiler.
+func MallocID(p Profiler, size int, id StackID) {
+	if ip, ok := p.(IDProfiler); ok {
+		ip.MallocID(size, id)
+	} else {
+		p.Malloc(size, id.Stack())
+	}
+}
+
+// AsIDProfiler returns p as an IDProfiler, adapting it to fall back to Malloc
+// if needed. Hot loops use it to avoid a type assertion per allocation.
+func AsIDProfiler(p Profiler) IDProfiler {
+	if ip, ok := p.(IDProfiler); ok {
+		return ip
+	}
+	return idAdapter{Wrapper{p}}
+}
+
+type idAdapter struct {
+	Wrapper
+}
+
+func (a idAdapter) MallocID(size int, id StackID) { a.Malloc(size, id.Stack()) }
+
+// denseCounts is a profile indexed by StackID. It suits profilers that record
+// every allocation, which see all stacks anyway.
+type denseCounts []Alloc
+
+func (c *denseCounts) add(id StackID, objects, bytes int64) {
+	if int(id) >= len(*c) {
+		*c = append(*c, make(denseCounts, int(id)+1-len(*c))...)
+	}
+	(*c)[id].Objects += objects
+	(*c)[id].Bytes += bytes
+}
+
+func (c denseCounts) objects() int64 {
+	var n int64
+	for _, v := range c {
+		n += v.Objects
+	}
+	return n
+}
+
+// profile converts c into a Profile, which is nil if c has no allocations.
+func (c denseCounts) profile() Profile {
+	n := 0
+	for _, v := range c {
+		if v.Objects != 0 || v.Bytes != 0 {
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	p := make(Profile, n)
+	stacksMu.RLock()
+	defer stacksMu.RUnlock()
+	for id, v := range c {
+		if v.Objects != 0 || v.Bytes != 0 {
+			p[stacks[id]] = v
+		}
+	}
+	return p
+}
+
+// counts is an open addressing hash table from StackID to the allocations of
+// a profile. Unlike a slice indexed by StackID, its size is proportional to
+// the number of stacks a profiler has seen rather than all interned stacks,
+// which matters for sampling profilers in workloads with millions of stacks.
+// Lookups are slower than for denseCounts, but only happen per sample.
+type counts struct {
+	slots []countSlot
+	len   int
+	// total is the sum of the objects of all slots.
+	total int64
+}
+
+type countSlot struct {
+	key   StackID // StackID+1, or 0 for an empty slot
+	alloc Alloc
+}
+
+func (c *counts) add(id StackID, objects, bytes int64) {
+	s := c.slot(id)
+	if s == nil || s.key == 0 {
+		if 4*(c.len+1) > 3*len(c.slots) {
+			c.grow()
+			s = c.slot(id)
+		}
+		s.key = id + 1
+		c.len++
+	}
+	s.alloc.Objects += objects
+	s.alloc.Bytes += bytes
+	c.total += objects
+}
+
+// slot returns the slot holding id, or the empty slot where it belongs. It
+// returns nil if c has no slots yet.
+func (c *counts) slot(id StackID) *countSlot {
+	if len(c.slots) == 0 {
+		return nil
+	}
+	mask := uint32(len(c.slots) - 1)
+	for i := (uint32(id) * 0x9e3779b9) & mask; ; i = (i + 1) & mask {
+		if s := &c.slots[i]; s.key == id+1 || s.key == 0 {
+			return s
+		}
+	}
+}
+
+func (c *counts) grow() {
+	old := c.slots
+	n := 2 * len(old)
+	if n == 0 {
+		n = 8
+	}
+	c.slots = make([]countSlot, n)
+	for _, s := range old {
+		if s.key != 0 {
+			*c.slot(s.key - 1) = s
+		}
+	}
+}
+
+func (c *counts) objects() int64 { return c.total }
+
+// profile converts c into a Profile, which is nil if c has no allocations.
+func (c *counts) profile() Profile {
+	if c.len == 0 {
+		return nil
+	}
+	p := make(Profile, c.len)
+	stacksMu.RLock()
+	defer stacksMu.RUnlock()
+	for _, s := range c.slots {
+		if s.alloc.Objects != 0 || s.alloc.Bytes != 0 {
+			p[stacks[s.key-1]] = s.alloc
+		}
+	}
+	if len(p) == 0 {
+		return nil
+	}
+	return p
+}
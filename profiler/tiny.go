@@ -0,0 +1,174 @@
+package profiler
+
+// TinySize is the size of the blocks of the tiny allocator of the Go runtime,
+// which combines the allocations of smaller objects without pointers.
+const TinySize = 16
+
+// TinyAlloc returns a middleware that combines allocations of less than
+// TinySize bytes into blocks like the tiny allocator of the Go runtime, see
+// mallocgc in runtime/malloc.go. All of them are assumed to have no pointers.
+// Allocations that fit into the current block of their P are never seen by
+// the profiler. One that needs a new block is seen as an allocation of
+// TinySize bytes, so the block is attributed to its stack whatever else ends
+// up in it.
+//
+// If frees is set, a block is freed once all objects in it are, and each free
+// takes the oldest live object of its stack and size. Otherwise frees of tiny
+// objects are ignored, which saves tracking which block holds each object.
+func TinyAlloc(frees bool) Middleware {
+	return func(p Profiler) Profiler {
+		t := &tinyAlloc{Wrapper: Wrapper{p}, procs: make([]tinyState, 1)}
+		if frees {
+			t.blocks = map[int64]*tinyBlock{}
+			t.live = map[liveKey][]tinyRun{}
+		}
+		return t
+	}
+}
+
+type tinyAlloc struct {
+	Wrapper
+	procs []tinyState
+	cur   int
+	// count counts the blocks allocated so far.
+	count int64
+	// blocks and live are nil unless frees are tracked.
+	blocks map[int64]*tinyBlock
+	live   map[liveKey][]tinyRun
+}
+
+// tinyState is the current block of a P like in its mcache, with block 0
+// meaning none.
+type tinyState struct {
+	block int64
+	off   int
+}
+
+// tinyBlock is a block that holds live objects, seen by the profiler as an
+// allocation at id.
+type tinyBlock struct {
+	id      StackID
+	objects int
+}
+
+// tinyRun is a number of objects in the same block.
+type tinyRun struct {
+	block   int64
+	objects int
+}
+
+func (p *tinyAlloc) SetProc(proc int) {
+	for len(p.procs) <= proc {
+		p.procs = append(p.procs, tinyState{})
+	}
+	p.cur = proc
+	SetProc(p.Profiler, proc)
+}
+
+func (p *tinyAlloc) Malloc(size int, stack StackTrace) { p.MallocID(size, Intern(stack)) }
+func (p *tinyAlloc) MallocID(size int, id StackID)     { p.MallocN(size, 1, id) }
+
+func (p *tinyAlloc) MallocN(size int, count int64, id StackID) {
+	if size <= 0 || size >= TinySize {
+		MallocN(p.Profiler, size, count, id)
+		return
+	}
+	var blocks int64
+	for ; count > 0; count-- {
+		if !p.place(size, id) {
+			blocks++
+		}
+	}
+	switch blocks {
+	case 0:
+	case 1:
+		MallocID(p.Profiler, TinySize, id)
+	default:
+		MallocN(p.Profiler, TinySize, blocks, id)
+	}
+}
+
+// place puts an object of size into the current block of the P and reports
+// whether it fit, or into a new block otherwise. Like the runtime, the new
+// block only becomes the current one if it has more space left.
+func (p *tinyAlloc) place(size int, id StackID) bool {
+	s := &p.procs[p.cur]
+	if off := tinyAlign(s.off, size); s.block != 0 && off+size <= TinySize {
+		s.off = off + size
+		p.track(s.block, size, id)
+		return true
+	}
+	p.count++
+	if p.blocks != nil {
+		p.blocks[p.count] = &tinyBlock{id: id}
+	}
+	p.track(p.count, size, id)
+	if s.block == 0 || size < s.off {
+		s.block, s.off = p.count, size
+	}
+	return false
+}
+
+// tinyAlign aligns off for an object of size like the runtime.
+func tinyAlign(off, size int) int {
+	switch {
+	case size&7 == 0:
+		return (off + 7) &^ 7
+	case size&3 == 0:
+		return (off + 3) &^ 3
+	case size&1 == 0:
+		return (off + 1) &^ 1
+	}
+	return off
+}
+
+// track records an object of size at id in block if frees are tracked.
+func (p *tinyAlloc) track(block int64, size int, id StackID) {
+	if p.blocks == nil {
+		return
+	}
+	p.blocks[block].objects++
+	k := liveKey{id, size}
+	runs := p.live[k]
+	if n := len(runs); n > 0 && runs[n-1].block == block {
+		runs[n-1].objects++
+	} else {
+		runs = append(runs, tinyRun{block: block, objects: 1})
+	}
+	p.live[k] = runs
+}
+
+func (p *tinyAlloc) Free(size int, stack StackTrace) { p.FreeID(size, Intern(stack)) }
+
+func (p *tinyAlloc) FreeID(size int, id StackID) {
+	if size <= 0 || size >= TinySize {
+		FreeID(p.Profiler, size, id)
+		return
+	}
+	k := liveKey{id, size}
+	runs := p.live[k]
+	if len(runs) == 0 {
+		return
+	}
+	block := runs[0].block
+	if runs[0].objects--; runs[0].objects == 0 {
+		runs = runs[1:]
+	}
+	if len(runs) == 0 {
+		delete(p.live, k)
+	} else {
+		p.live[k] = runs
+	}
+	b := p.blocks[block]
+	if b.objects--; b.objects > 0 {
+		return
+	}
+	// A free block can't stay the current one of a P.
+	delete(p.blocks, block)
+	for i := range p.procs {
+		if p.procs[i].block == block {
+			p.procs[i] = tinyState{}
+		}
+	}
+	FreeID(p.Profiler, TinySize, b.id)
+}
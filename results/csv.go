@@ -0,0 +1,251 @@
+package results
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+// Schema is the version of the CSV format written by WriteCSV. It must be
+// incremented and its columns appended to schemas whenever columns are added,
+// removed or change their meaning.
+const Schema = 10
+
+// schemas holds the columns of each schema version, starting with version 1.
+// Files written before versioning was introduced are identified by their
+// columns.
+var schemas = [][]string{
+	{"profiler", "workload", "stack", "objects", "bytes"},
+	{"profiler", "workload", "rate", "stack", "objects", "bytes"},
+	{"profiler", "workload", "rate", "ops", "stack", "objects", "bytes"},
+	{"profiler", "workload", "rate", "ops", "trial", "seed", "stack", "objects", "bytes"},
+	{"profiler", "workload", "rate", "ops", "trial", "seed", "formula", "stack", "objects", "bytes"},
+	{"profiler", "workload", "rate", "ops", "trial", "seed", "formula", "stack", "objects", "bytes", "inuse_objects", "inuse_bytes"},
+	{"profiler", "workload", "rate", "ops", "trial", "seed", "formula", "stack", "objects", "bytes", "inuse_objects", "inuse_bytes", "sampled_objects", "sampled_bytes"},
+	{"profiler", "workload", "rate", "ops", "trial", "seed", "formula", "stack", "objects", "bytes", "inuse_objects", "inuse_bytes", "sampled_objects", "sampled_bytes", "overhead"},
+	{"profiler", "workload", "rate", "ops", "trial", "seed", "formula", "stack", "objects", "bytes", "inuse_objects", "inuse_bytes", "sampled_objects", "sampled_bytes", "overhead", "peak_objects", "peak_bytes"},
+	{"profiler", "workload", "rate", "ops", "trial", "seed", "formula", "stack", "objects", "bytes", "inuse_objects", "inuse_bytes", "sampled_objects", "sampled_bytes", "overhead", "peak_objects", "peak_bytes", "sizes", "sizes_distance"},
+}
+
+// schemaPrefix starts the first line of a CSV file, followed by its schema
+// version. Tools that don't understand it can skip it as a comment, e.g. with
+// pandas.read_csv(..., comment="#").
+const schemaPrefix = "# schema="
+
+// Columns returns the columns of the current schema.
+func Columns() []string { return append([]string(nil), schemas[Schema-1]...) }
+
+// Row is a row of the CSV format. Objects, Bytes and their in-use counterparts
+// are kept as text because they hold relative errors such as "-1.23%" when
+// errors are reported. SampledObjects and SampledBytes hold the raw samples
+// the estimates rest on, even when errors are reported. Overhead is the
+// simulated overhead of the profiler for the whole cell, e.g. "1.23%", see
+// stats.Costs, and empty for the reference profilers. PeakObjects and
+// PeakBytes are the in-use values at the peak of the true live heap, or their
+// errors. Sizes is the size histogram of the stack, see profiler.SizeHistogram,
+// or its error, see stats.HistogramError, in which case SizesDistance holds the
+// distance between the shapes of the histograms, see stats.HistogramDistance.
+// Columns missing from older schemas are left empty.
+type Row struct {
+	Profiler string
+	Workload string
+	Rate     int
+	Ops      int64
+	Trial    int
+	Seed     int64
+	Formula  profiler.ScaleFormula
+	Stack    profiler.StackTrace
+	Objects  string
+	Bytes    string
+
+	InUseObjects string
+	InUseBytes   string
+
+	SampledObjects string
+	SampledBytes   string
+	Overhead       string
+
+	PeakObjects string
+	PeakBytes   string
+
+	Sizes         string
+	SizesDistance string
+}
+
+// Strings returns the fields of r in the order of Columns.
+func (r Row) Strings() []string {
+	return []string{
+		r.Profiler,
+		r.Workload,
+		strconv.Itoa(r.Rate),
+		strconv.FormatInt(r.Ops, 10),
+		strconv.Itoa(r.Trial),
+		strconv.FormatInt(r.Seed, 10),
+		string(r.Formula),
+		string(r.Stack),
+		r.Objects,
+		r.Bytes,
+		r.InUseObjects,
+		r.InUseBytes,
+		r.SampledObjects,
+		r.SampledBytes,
+		r.Overhead,
+		r.PeakObjects,
+		r.PeakBytes,
+		r.Sizes,
+		r.SizesDistance,
+	}
+}
+
+// WriteCSV writes the schema version, a header and rows to w.
+func WriteCSV(w io.Writer, rows []Row) error {
+	cw, err := NewCSVWriter(w)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		cw.Write(row)
+	}
+	return cw.Flush()
+}
+
+// CSVWriter writes rows one at a time in the format of WriteCSV, so that
+// they don't need to be held in memory.
+type CSVWriter struct{ cw *csv.Writer }
+
+// NewCSVWriter writes the schema version and a header to w.
+func NewCSVWriter(w io.Writer) (*CSVWriter, error) {
+	if _, err := fmt.Fprintf(w, "%s%d\n", schemaPrefix, Schema); err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(Columns())
+	return &CSVWriter{cw: cw}, nil
+}
+
+// Write writes row. Errors are reported by Flush.
+func (w *CSVWriter) Write(row Row) { w.cw.Write(row.Strings()) }
+
+// Flush writes any buffered rows and returns the first error that occurred.
+func (w *CSVWriter) Flush() error {
+	w.cw.Flush()
+	return w.cw.Error()
+}
+
+// ReadCSV reads rows written by any version of WriteCSV and returns the schema
+// version of the input. Columns missing from older versions are left at their
+// zero values.
+func ReadCSV(r io.Reader) (int, []Row, error) {
+	br := bufio.NewReader(r)
+	schema := 0
+	if b, err := br.Peek(len(schemaPrefix)); err == nil && string(b) == schemaPrefix {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return 0, nil, err
+		}
+		schema, err = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, schemaPrefix)))
+		if err != nil || schema < 1 {
+			return 0, nil, fmt.Errorf("bad schema line: %q", strings.TrimSpace(line))
+		} else if schema > Schema {
+			return 0, nil, fmt.Errorf("schema %d is newer than the supported schema %d", schema, Schema)
+		}
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return 0, nil, errors.New("missing header")
+	} else if err != nil {
+		return 0, nil, err
+	}
+	if schema == 0 {
+		for i, columns := range schemas {
+			if strings.Join(columns, ",") == strings.Join(header, ",") {
+				schema = i + 1
+			}
+		}
+		if schema == 0 {
+			return 0, nil, fmt.Errorf("unknown header: %q", strings.Join(header, ","))
+		}
+	} else if strings.Join(schemas[schema-1], ",") != strings.Join(header, ",") {
+		return 0, nil, fmt.Errorf("header doesn't match schema %d: %q", schema, strings.Join(header, ","))
+	}
+
+	var rows []Row
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return schema, rows, nil
+		} else if err != nil {
+			return 0, nil, err
+		} else if len(record) != len(header) {
+			return 0, nil, fmt.Errorf("line %d: want %d fields, got %d", line, len(header), len(record))
+		}
+		row, err := parseRow(header, record)
+		if err != nil {
+			return 0, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func parseRow(header, record []string) (Row, error) {
+	var (
+		row Row
+		err error
+	)
+	for i, column := range header {
+		v := record[i]
+		switch column {
+		case "profiler":
+			row.Profiler = v
+		case "workload":
+			row.Workload = v
+		case "rate":
+			row.Rate, err = strconv.Atoi(v)
+		case "ops":
+			row.Ops, err = strconv.ParseInt(v, 10, 64)
+		case "trial":
+			row.Trial, err = strconv.Atoi(v)
+		case "seed":
+			row.Seed, err = strconv.ParseInt(v, 10, 64)
+		case "formula":
+			row.Formula = profiler.ScaleFormula(v)
+		case "stack":
+			row.Stack = profiler.StackTrace(v)
+		case "objects":
+			row.Objects = v
+		case "bytes":
+			row.Bytes = v
+		case "inuse_objects":
+			row.InUseObjects = v
+		case "inuse_bytes":
+			row.InUseBytes = v
+		case "sampled_objects":
+			row.SampledObjects = v
+		case "sampled_bytes":
+			row.SampledBytes = v
+		case "overhead":
+			row.Overhead = v
+		case "peak_objects":
+			row.PeakObjects = v
+		case "peak_bytes":
+			row.PeakBytes = v
+		case "sizes":
+			row.Sizes = v
+		case "sizes_distance":
+			row.SizesDistance = v
+		}
+		if err != nil {
+			return Row{}, fmt.Errorf("bad %s: %q", column, v)
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,71 @@
+package results
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	rows := []Row{{
+		Profiler:       "go",
+		Workload:       "sequential",
+		Rate:           512 << 10,
+		Ops:            1000000,
+		Trial:          2,
+		Seed:           -7,
+		Formula:        "ht",
+		Stack:          "main;alloc",
+		Objects:        "-1.23%",
+		Bytes:          "4.56%",
+		InUseObjects:   "12",
+		InUseBytes:     "1536",
+		SampledObjects: "3",
+		SampledBytes:   "384",
+		Overhead:       "0.10%",
+		PeakObjects:    "20",
+		PeakBytes:      "2560",
+		Sizes:          "128:3",
+		SizesDistance:  "0.00%",
+	}, {
+		// Reference rows leave the overhead empty, and stacks may need
+		// quoting.
+		Profiler: "perfect",
+		Workload: "sequential",
+		Stack:    `main;"quoted, with comma"`,
+		Objects:  "10",
+		Bytes:    "1280",
+	}}
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	schema, got, err := ReadCSV(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema != Schema {
+		t.Errorf("read schema %d, want %d", schema, Schema)
+	}
+	if !reflect.DeepEqual(got, rows) {
+		t.Errorf("read\n%+v\nwant\n%+v", got, rows)
+	}
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"# schema=11\n" + strings.Join(Columns(), ",") + "\n",
+		"# schema=x\n",
+		"# schema=0\n",
+		"# schema=2\nprofiler,workload,stack,objects,bytes\n",
+		"profiler,workload,stack\n",
+		"profiler,workload,stack,objects,bytes\ngo,w,main\n",
+		"profiler,workload,rate,stack,objects,bytes\ngo,w,x,main,1,2\n",
+	} {
+		if _, rows, err := ReadCSV(strings.NewReader(in)); err == nil {
+			t.Errorf("ReadCSV(%q) = %v, want error", in, rows)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package results collects the profiles produced by simulations.
+package results
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+// New returns empty results.
+func New() *Results {
+	return &Results{Index: make(map[Key]profiler.Profile)}
+}
+
+// Results holds the profiles produced by a simulation.
+type Results struct {
+	List  []Result
+	Index map[Key]profiler.Profile
+
+	mu sync.Mutex
+}
+
+// Add adds the profile for key. It is safe to call concurrently.
+func (r *Results) Add(key Key, profile profiler.Profile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Index[key] = profile
+	r.List = append(r.List, Result{Key: key, Profile: profile})
+}
+
+// Lock prevents concurrent calls to Add until Unlock is called.
+func (r *Results) Lock()   { r.mu.Lock() }
+func (r *Results) Unlock() { r.mu.Unlock() }
+
+// Len returns the number of results.
+func (r *Results) Len() int { return len(r.List) }
+
+// Get returns the profile for key.
+func (r *Results) Get(key Key) (profiler.Profile, bool) {
+	p, ok := r.Index[key]
+	return p, ok
+}
+
+// Each calls fn with each result in the order they were added. The caller
+// must not add results concurrently.
+func (r *Results) Each(fn func(Result) error) error {
+	for _, res := range r.List {
+		if err := fn(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UniqueStacks returns the sorted stacks of all profiles for the workload.
+func (r *Results) UniqueStacks(workload string) []profiler.StackTrace {
+	stacks := []profiler.StackTrace{}
+	seen := map[profiler.StackTrace]bool{}
+	for key, p := range r.Index {
+		if key.Workload != workload {
+			continue
+		}
+		for st := range p {
+			if seen[st] {
+				continue
+			}
+			stacks = append(stacks, st)
+			seen[st] = true
+		}
+	}
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i] < stacks[j] })
+	return stacks
+}
+
+// Result is the profile produced by a profiler for a workload.
+type Result struct {
+	Key
+	Profile profiler.Profile `json:"profile"`
+}
+
+// Key identifies a Result.
+type Key struct {
+	Workload string                `json:"workload"`
+	Profiler string                `json:"profiler"`
+	Rate     int                   `json:"rate"`
+	Ops      int64                 `json:"ops"`
+	Trial    int                   `json:"trial"`
+	Seed     int64                 `json:"seed"`
+	Formula  profiler.ScaleFormula `json:"formula"`
+}
@@ -0,0 +1,134 @@
+package results
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+// Spill holds results in an append-only file instead of memory, for sweeps
+// whose profiles don't fit into memory. Only the keys, the position of each
+// profile in the file and the stacks of each workload are kept in memory.
+type Spill struct {
+	f      *os.File
+	w      *bufio.Writer
+	size   int64
+	keys   []Key
+	index  map[Key]span
+	stacks map[string]map[profiler.StackTrace]bool
+	err    error
+
+	mu sync.Mutex
+}
+
+// span is the position of a result in a Spill file.
+type span struct{ off, len int64 }
+
+// CreateSpill returns empty results that are stored in a new temporary file
+// in dir, or the default directory for temporary files if dir is empty. Close
+// removes the file.
+func CreateSpill(dir string) (*Spill, error) {
+	f, err := os.CreateTemp(dir, "alloc-prof-sim-spill-")
+	if err != nil {
+		return nil, err
+	}
+	return &Spill{
+		f:      f,
+		w:      bufio.NewWriter(f),
+		index:  map[Key]span{},
+		stacks: map[string]map[profiler.StackTrace]bool{},
+	}, nil
+}
+
+// Add appends the profile for key to the file. It is safe to call
+// concurrently. Write errors are returned by Each.
+func (s *Spill) Add(key Key, profile profiler.Profile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	data, err := json.Marshal(Result{Key: key, Profile: profile})
+	if err != nil {
+		s.err = err
+		return
+	}
+	data = append(data, '\n')
+	if _, err := s.w.Write(data); err != nil {
+		s.err = err
+		return
+	}
+	s.index[key] = span{off: s.size, len: int64(len(data))}
+	s.keys = append(s.keys, key)
+	s.size += int64(len(data))
+
+	seen := s.stacks[key.Workload]
+	if seen == nil {
+		seen = map[profiler.StackTrace]bool{}
+		s.stacks[key.Workload] = seen
+	}
+	for st := range profile {
+		seen[st] = true
+	}
+}
+
+// Lock prevents concurrent calls to Add until Unlock is called.
+func (s *Spill) Lock()   { s.mu.Lock() }
+func (s *Spill) Unlock() { s.mu.Unlock() }
+
+// Len returns the number of results.
+func (s *Spill) Len() int { return len(s.keys) }
+
+// Get reads the profile for key from the file.
+func (s *Spill) Get(key Key) (profiler.Profile, bool) {
+	sp, ok := s.index[key]
+	if !ok || s.w.Flush() != nil {
+		return nil, false
+	}
+	var r Result
+	if err := json.NewDecoder(io.NewSectionReader(s.f, sp.off, sp.len)).Decode(&r); err != nil {
+		return nil, false
+	}
+	return r.Profile, true
+}
+
+// Each reads the results from the file and calls fn with each of them in the
+// order they were added. The caller must not add results concurrently.
+func (s *Spill) Each(fn func(Result) error) error {
+	if s.err != nil {
+		return s.err
+	} else if err := s.w.Flush(); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bufio.NewReader(io.NewSectionReader(s.f, 0, s.size)))
+	for range s.keys {
+		var r Result
+		if err := dec.Decode(&r); err != nil {
+			return err
+		} else if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UniqueStacks returns the sorted stacks of all profiles for the workload.
+func (s *Spill) UniqueStacks(workload string) []profiler.StackTrace {
+	stacks := []profiler.StackTrace{}
+	for st := range s.stacks[workload] {
+		stacks = append(stacks, st)
+	}
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i] < stacks[j] })
+	return stacks
+}
+
+// Close removes the file.
+func (s *Spill) Close() error {
+	s.f.Close()
+	return os.Remove(s.f.Name())
+}
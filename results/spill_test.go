@@ -0,0 +1,68 @@
+package results
+
+import (
+	"bufio"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+// TestSpill checks that a Spill returns the same results in the same order as
+// Results.
+func TestSpill(t *testing.T) {
+	s, err := CreateSpill(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// The smallest buffer makes every result go through the file rather than
+	// the buffer.
+	s.w = bufio.NewWriterSize(s.f, 16)
+	mem := New()
+
+	var keys []Key
+	for i := 0; i < 50; i++ {
+		key := Key{Workload: fmt.Sprintf("w%d", i%3), Profiler: "go", Rate: 512, Ops: 1000, Trial: i, Seed: int64(i), Formula: profiler.ScaleHT}
+		profile := profiler.Profile{}
+		for j := 0; j <= i%4; j++ {
+			st := profiler.StackTrace(fmt.Sprintf("main;f%d", (i+j)%7))
+			profile[st] = profiler.Alloc{Objects: int64(i + j), Bytes: int64(i+j) * 16, InUseObjects: int64(j), SampledObjects: int64(j)}
+		}
+		s.Add(key, profile)
+		mem.Add(key, profile)
+		keys = append(keys, key)
+		// Reading between adds must not disturb the appending.
+		if i%10 == 0 {
+			if got, ok := s.Get(keys[i/2]); !ok || !reflect.DeepEqual(got, mem.Index[keys[i/2]]) {
+				t.Fatalf("Get(%v) before adding all = %v, %v, want %v", keys[i/2], got, ok, mem.Index[keys[i/2]])
+			}
+		}
+	}
+
+	if s.Len() != mem.Len() {
+		t.Errorf("Len() = %d, want %d", s.Len(), mem.Len())
+	}
+	var got, want []Result
+	if err := s.Each(func(r Result) error { got = append(got, r); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	mem.Each(func(r Result) error { want = append(want, r); return nil })
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Each gives\n%v\nwant\n%v", got, want)
+	}
+	for _, key := range keys {
+		if got, ok := s.Get(key); !ok || !reflect.DeepEqual(got, mem.Index[key]) {
+			t.Errorf("Get(%v) = %v, %v, want %v", key, got, ok, mem.Index[key])
+		}
+	}
+	if _, ok := s.Get(Key{Workload: "missing"}); ok {
+		t.Error("Get of a missing key succeeded")
+	}
+	for _, w := range []string{"w0", "w1", "w2", "missing"} {
+		if got, want := s.UniqueStacks(w), mem.UniqueStacks(w); !reflect.DeepEqual(got, want) {
+			t.Errorf("UniqueStacks(%q) = %v, want %v", w, got, want)
+		}
+	}
+}
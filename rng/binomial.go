@@ -0,0 +1,66 @@
+package rng
+
+import (
+	"math"
+	"math/rand"
+)
+
+// binomialDirect is the n below which Binomial draws one Bernoulli trial at a
+// time.
+const binomialDirect = 32
+
+// Binomial returns the number of successes in n independent trials with
+// success probability p. It takes O(log n) time using Devroye's recursive
+// splitting on the order statistics of the uniform distribution, so it is
+// exact for any n unlike the normal approximation.
+func Binomial(r *rand.Rand, n int64, p float64) int64 {
+	var k int64
+	for n > binomialDirect && p > 0 && p < 1 {
+		// x is the a-th smallest of n uniform variates.
+		a := n/2 + 1
+		x := beta(r, float64(a), float64(n+1-a))
+		if p < x {
+			n, p = a-1, p/x
+		} else {
+			k += a
+			n, p = n-a, (p-x)/(1-x)
+		}
+	}
+	switch {
+	case p <= 0:
+		return k
+	case p >= 1:
+		return k + n
+	}
+	for i := int64(0); i < n; i++ {
+		if r.Float64() < p {
+			k++
+		}
+	}
+	return k
+}
+
+// beta returns a Beta(a, b) variate for a, b >= 1.
+func beta(r *rand.Rand, a, b float64) float64 {
+	x := gamma(r, a)
+	return x / (x + gamma(r, b))
+}
+
+// gamma returns a Gamma(a, 1) variate for a >= 1 using the method of Marsaglia
+// and Tsang.
+func gamma(r *rand.Rand, a float64) float64 {
+	d := a - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := r.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := r.Float64()
+		if u < 1-0.0331*x*x*x*x || math.Log(u) < x*x/2+d*(1-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
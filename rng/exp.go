@@ -0,0 +1,70 @@
+package rng
+
+import "math"
+
+// Float64 returns a uniform variate in [0, 1).
+func (r *Wyrand) Float64() float64 {
+	return float64(r.Uint64()>>11) / (1 << 53)
+}
+
+// ExpFloat64 returns a standard exponential variate using the ziggurat method
+// of Marsaglia and Tsang. Unlike rand.Rand.ExpFloat64 it takes the layer and
+// the candidate from a single 64-bit draw without sharing bits between them,
+// and it avoids the indirection through rand.Source.
+func (r *Wyrand) ExpFloat64() float64 {
+	for {
+		u := r.Uint64()
+		i := u >> 56
+		j := uint32(u)
+		x := float64(j) * expW[i]
+		if j < expK[i] {
+			return x
+		}
+		if i == 0 {
+			return expR - math.Log(1-r.Float64())
+		}
+		if expF[i]+r.Float64()*(expF[i-1]-expF[i]) < math.Exp(-x) {
+			return x
+		}
+	}
+}
+
+// ExpFloat64s fills dst with standard exponential variates. It is faster
+// than calling ExpFloat64 through a function value for each of them.
+func (r *Wyrand) ExpFloat64s(dst []float64) {
+	for i := range dst {
+		dst[i] = r.ExpFloat64()
+	}
+}
+
+// expR is the start of the tail of the 256 layer exponential ziggurat and expV
+// the area of each layer.
+const (
+	expR = 7.69711747013104972
+	expV = 3.949659822581572e-3
+)
+
+var (
+	expK [256]uint32
+	expW [256]float64
+	expF [256]float64
+)
+
+func init() {
+	const m = 1 << 32
+	d, t := expR, expR
+	q := expV / math.Exp(-d)
+	expK[0] = uint32(d / q * m)
+	expK[1] = 0
+	expW[0] = q / m
+	expW[255] = d / m
+	expF[0] = 1
+	expF[255] = math.Exp(-d)
+	for i := 254; i >= 1; i-- {
+		d = -math.Log(expV/d + math.Exp(-d))
+		expK[i+1] = uint32(d / t * m)
+		t = d
+		expF[i] = math.Exp(-d)
+		expW[i] = d / m
+	}
+}
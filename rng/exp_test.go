@@ -0,0 +1,105 @@
+package rng
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// TestExpFloat64 checks that ExpFloat64 follows the standard exponential
+// distribution like rand.ExpFloat64: its mean, variance, quantiles and the
+// Kolmogorov-Smirnov distance of n draws to Exp(1), and the tail beyond the
+// last layer of the ziggurat, which is drawn separately.
+func TestExpFloat64(t *testing.T) {
+	const n = 1000000
+	r := NewWyrand(1)
+	xs := make([]float64, n)
+	var sum, sumSq float64
+	for i := range xs {
+		x := r.ExpFloat64()
+		xs[i] = x
+		sum += x
+		sumSq += x * x
+	}
+
+	// Exp(1) has mean and variance 1, and its variance has a standard
+	// deviation of sqrt(8) across draws.
+	mean := sum / n
+	variance := sumSq/n - mean*mean
+	if math.Abs(mean-1) > 5/math.Sqrt(n) {
+		t.Errorf("mean %.5f, want 1", mean)
+	}
+	if math.Abs(variance-1) > 5*math.Sqrt(8)/math.Sqrt(n) {
+		t.Errorf("variance %.5f, want 1", variance)
+	}
+
+	sort.Float64s(xs)
+	cdf := func(x float64) float64 { return -math.Expm1(-x) }
+	var d float64
+	for i, x := range xs {
+		d = math.Max(d, math.Max(float64(i+1)/n-cdf(x), cdf(x)-float64(i)/n))
+	}
+	// The critical value of the Kolmogorov distribution at a level of
+	// 0.001 is 1.95.
+	if d*math.Sqrt(n) > 1.95 {
+		t.Errorf("Kolmogorov-Smirnov distance %.5f, want below %.5f", d, 1.95/math.Sqrt(n))
+	}
+	for _, p := range []float64{0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		got, want := xs[int(p*n)], -math.Log(1-p)
+		// The standard error of the p quantile is sqrt(p/(1-p)/n).
+		if math.Abs(got-want) > 5*math.Sqrt(p/(1-p)/n) {
+			t.Errorf("%v quantile %.4f, want %.4f", p, got, want)
+		}
+	}
+
+	// A share of e^-expR of the draws lies in the tail, and beyond expR
+	// they are again Exp(1) distributed as the distribution is memoryless.
+	// It takes more draws to see enough of them.
+	const tailN = 20 * n
+	var tail int
+	var tailSum, tailSumSq float64
+	for i := 0; i < tailN; i++ {
+		if x := r.ExpFloat64(); x > expR {
+			tail++
+			tailSum += x - expR
+			tailSumSq += (x - expR) * (x - expR)
+		}
+	}
+	wantTail := tailN * math.Exp(-expR)
+	if math.Abs(float64(tail)-wantTail) > 5*math.Sqrt(wantTail) {
+		t.Errorf("%d draws beyond %.2f, want about %.0f", tail, expR, wantTail)
+	}
+	tailMean := tailSum / float64(tail)
+	tailVariance := tailSumSq/float64(tail) - tailMean*tailMean
+	if math.Abs(tailMean-1) > 5/math.Sqrt(float64(tail)) {
+		t.Errorf("mean excess beyond %.2f is %.3f, want 1", expR, tailMean)
+	}
+	if math.Abs(tailVariance-1) > 5*math.Sqrt(8)/math.Sqrt(float64(tail)) {
+		t.Errorf("variance of the excess beyond %.2f is %.3f, want 1", expR, tailVariance)
+	}
+}
+
+var sinkFloat64 float64
+
+func BenchmarkExpFloat64(b *testing.B) {
+	b.Run("wyrand", func(b *testing.B) {
+		r := NewWyrand(1)
+		for i := 0; i < b.N; i++ {
+			sinkFloat64 = r.ExpFloat64()
+		}
+	})
+	b.Run("wyrand-fill", func(b *testing.B) {
+		r := NewWyrand(1)
+		buf := make([]float64, 256)
+		for i := 0; i < b.N; i += len(buf) {
+			r.ExpFloat64s(buf)
+		}
+	})
+	b.Run("math-rand", func(b *testing.B) {
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < b.N; i++ {
+			sinkFloat64 = r.ExpFloat64()
+		}
+	})
+}
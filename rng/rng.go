@@ -0,0 +1,85 @@
+// Package rng implements fast random number sources for simulations.
+package rng
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand"
+)
+
+// Wyrand is the wyrand generator by Wang Yi. It passes BigCrush and
+// PractRand, is seeded in constant time and is several times faster than the
+// default math/rand source. It implements rand.Source64.
+type Wyrand struct {
+	state uint64
+}
+
+// NewWyrand returns a generator seeded with seed.
+func NewWyrand(seed int64) *Wyrand {
+	return &Wyrand{state: uint64(seed)}
+}
+
+func (r *Wyrand) Seed(seed int64) { r.state = uint64(seed) }
+
+func (r *Wyrand) Uint64() uint64 {
+	r.state += wyIncrement
+	hi, lo := bits.Mul64(r.state, r.state^0xe7037ed1a0b428db)
+	return hi ^ lo
+}
+
+func (r *Wyrand) Int63() int64 { return int64(r.Uint64() >> 1) }
+
+const wyIncrement = 0xa0761d6478bd642f
+
+// Jump advances r by n draws in constant time.
+func (r *Wyrand) Jump(n uint64) { r.state += n * wyIncrement }
+
+// Sources lists the names accepted by New.
+var Sources = []string{"wyrand", "go"}
+
+// New returns a random number generator using the named source seeded with
+// seed. The go source is the default source of math/rand, which is slower to
+// seed and generate numbers but reproduces results of earlier versions.
+func New(source string, seed int64) (*rand.Rand, error) {
+	src, err := NewSource(source, seed)
+	if err != nil {
+		return nil, err
+	}
+	return rand.New(src), nil
+}
+
+// StreamLen is the number of draws after which the streams returned by
+// NewStream for the wyrand source start to overlap.
+const StreamLen = 1 << 48
+
+// NewStream returns the source of stream i of seed, e.g. for one of several
+// parts of a simulation that run concurrently. Stream 0 is the same as
+// NewSource. For wyrand, stream i starts i*StreamLen draws into the sequence
+// of seed, so the streams never overlap in practice. The go source can't
+// jump, so its other streams are seeded with a hash of seed and i instead.
+func NewStream(source string, seed int64, i int) (rand.Source, error) {
+	if i > 0 && source == "go" {
+		z := uint64(seed) + uint64(i)*wyIncrement
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		seed = int64(z ^ (z >> 31))
+	}
+	src, err := NewSource(source, seed)
+	if w, ok := src.(*Wyrand); ok {
+		w.Jump(uint64(i) * StreamLen)
+	}
+	return src, err
+}
+
+// NewSource is like New, but returns the source itself, e.g. to use the
+// methods of *Wyrand directly.
+func NewSource(source string, seed int64) (rand.Source, error) {
+	switch source {
+	case "wyrand", "":
+		return NewWyrand(seed), nil
+	case "go":
+		return rand.NewSource(seed), nil
+	default:
+		return nil, fmt.Errorf("unknown random number source: %q", source)
+	}
+}
@@ -0,0 +1,150 @@
+package rng
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// draws returns the first n values of src.
+func draws(src rand.Source, n int) []int64 {
+	xs := make([]int64, n)
+	for i := range xs {
+		xs[i] = src.Int63()
+	}
+	return xs
+}
+
+func equal(a, b []int64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+// TestDeterministic checks that each source gives the same stream for the same
+// seed and different ones for different seeds.
+func TestDeterministic(t *testing.T) {
+	for _, source := range Sources {
+		a, _ := NewSource(source, 42)
+		b, _ := NewSource(source, 42)
+		c, _ := NewSource(source, 43)
+		want := draws(a, 100)
+		if got := draws(b, 100); !equal(got, want) {
+			t.Errorf("%s: seed 42 gives %v and %v", source, got[:3], want[:3])
+		}
+		if got := draws(c, 100); equal(got, want) {
+			t.Errorf("%s: seeds 42 and 43 give the same stream", source)
+		}
+		a.Seed(42)
+		if got := draws(a, 100); !equal(got, want) {
+			t.Errorf("%s: reseeding gives %v, want %v", source, got[:3], want[:3])
+		}
+	}
+	if _, err := NewSource("mt", 1); err == nil {
+		t.Error("unknown source accepted")
+	}
+}
+
+func TestJump(t *testing.T) {
+	a, b := NewWyrand(7), NewWyrand(7)
+	for i := 0; i < 1000; i++ {
+		a.Uint64()
+	}
+	b.Jump(1000)
+	if x, y := a.Uint64(), b.Uint64(); x != y {
+		t.Errorf("jumping 1000 draws gives %d, drawing them %d", y, x)
+	}
+}
+
+// TestNewStream checks that stream 0 is the source itself, that streams are
+// reproducible, and that the streams of a seed are uncorrelated.
+func TestNewStream(t *testing.T) {
+	const n = 100000
+	for _, source := range Sources {
+		src, _ := NewSource(source, 1)
+		s0, _ := NewStream(source, 1, 0)
+		if !equal(draws(s0, 100), draws(src, 100)) {
+			t.Errorf("%s: stream 0 differs from the source", source)
+		}
+		var streams [][]float64
+		for i := 1; i <= 3; i++ {
+			a, _ := NewStream(source, 1, i)
+			b, _ := NewStream(source, 1, i)
+			if !equal(draws(a, 100), draws(b, 100)) {
+				t.Errorf("%s: stream %d isn't reproducible", source, i)
+			}
+			s, _ := NewStream(source, 1, i)
+			r := rand.New(s)
+			xs := make([]float64, n)
+			for j := range xs {
+				xs[j] = r.Float64()
+			}
+			streams = append(streams, xs)
+		}
+		// The correlation of independent streams has a standard error of
+		// 1/sqrt(n).
+		for i := range streams {
+			for j := i + 1; j < len(streams); j++ {
+				if c := correlation(streams[i], streams[j]); math.Abs(c) > 5/math.Sqrt(n) {
+					t.Errorf("%s: streams %d and %d have a correlation of %.4f", source, i+1, j+1, c)
+				}
+			}
+		}
+	}
+	// Wyrand streams are far apart in the same sequence.
+	a, _ := NewStream("wyrand", 1, 2)
+	b := NewWyrand(1)
+	b.Jump(2 * StreamLen)
+	if !equal(draws(a, 10), draws(b, 10)) {
+		t.Error("wyrand stream 2 doesn't start 2*StreamLen draws into the sequence")
+	}
+}
+
+func correlation(xs, ys []float64) float64 {
+	var mx, my float64
+	for i := range xs {
+		mx += xs[i]
+		my += ys[i]
+	}
+	mx /= float64(len(xs))
+	my /= float64(len(ys))
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+var sinkUint64 uint64
+
+func BenchmarkUint64(b *testing.B) {
+	b.Run("wyrand", func(b *testing.B) {
+		r := NewWyrand(1)
+		for i := 0; i < b.N; i++ {
+			sinkUint64 = r.Uint64()
+		}
+	})
+	b.Run("go", func(b *testing.B) {
+		r := rand.NewSource(1).(rand.Source64)
+		for i := 0; i < b.N; i++ {
+			sinkUint64 = r.Uint64()
+		}
+	})
+}
+
+// BenchmarkSplit measures creating the source of a stream, e.g. for a shard.
+func BenchmarkSplit(b *testing.B) {
+	for _, source := range Sources {
+		b.Run(source, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewStream(source, 1, i)
+			}
+		})
+	}
+}
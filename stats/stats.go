@@ -0,0 +1,215 @@
+// Package stats implements the error metrics used to evaluate profilers
+// against the true allocations.
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// RelError returns the relative error of got in percent.
+func RelError(got, want float64) float64 {
+	return (got - want) / want * 100
+}
+
+// ErrorPercent formats the relative error of got in percent.
+func ErrorPercent(got, want float64) string {
+	return fmt.Sprintf("%.2f%%", RelError(got, want))
+}
+
+// Costs assigns simulated CPU time to the allocations of a program and to the
+// work of a profiler, so that the overhead of profilers can be weighed against
+// their accuracy.
+type Costs struct {
+	// Alloc is the time the program spends per allocation without a
+	// profiler, including its other work.
+	Alloc time.Duration
+	// Check is the time a profiler adds to every allocation, e.g. to
+	// update its sampling counter.
+	Check time.Duration
+	// Sample is the time a profiler spends per sample, e.g. to unwind the
+	// stack and record it.
+	Sample time.Duration
+}
+
+// Overhead returns the time a profiler taking samples of allocs allocations
+// adds to the program in percent of its time without the profiler.
+func (c Costs) Overhead(allocs, samples int64) float64 {
+	added := float64(allocs)*float64(c.Check) + float64(samples)*float64(c.Sample)
+	return added / (float64(allocs) * float64(c.Alloc)) * 100
+}
+
+// MAPE returns the mean absolute percentage error of got relative to want,
+// which must have the same length. Pairs where want is zero are skipped, and
+// NaN is returned if no pairs remain.
+func MAPE(got, want []float64) float64 {
+	var sum float64
+	var n int
+	for i := range got {
+		if want[i] == 0 {
+			continue
+		}
+		sum += math.Abs(RelError(got[i], want[i]))
+		n++
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return sum / float64(n)
+}
+
+// HistogramError returns the sum of the absolute errors of the buckets of got
+// relative to the total of want in percent, which must have the same length.
+// It is zero only if all buckets are right, and counts objects both missing
+// from their bucket and reported in another one.
+func HistogramError(got, want []float64) float64 {
+	var diff, total float64
+	for i := range got {
+		diff += math.Abs(got[i] - want[i])
+		total += want[i]
+	}
+	return diff / total * 100
+}
+
+// HistogramDistance returns the total variation distance between the
+// distributions of got and want in percent, which must have the same length,
+// i.e. the share of got that would have to move to other buckets to match the
+// shape of want regardless of their totals. It is NaN if either is empty.
+func HistogramDistance(got, want []float64) float64 {
+	var gotTotal, wantTotal float64
+	for i := range got {
+		gotTotal += got[i]
+		wantTotal += want[i]
+	}
+	if gotTotal == 0 || wantTotal == 0 {
+		return math.NaN()
+	}
+	var d float64
+	for i := range got {
+		d += math.Abs(got[i]/gotTotal - want[i]/wantTotal)
+	}
+	return d / 2 * 100
+}
+
+// Check is the result of CheckMean.
+type Check int
+
+const (
+	CheckPassed Check = iota
+	CheckFailed
+	// CheckInconclusive means that the value was estimated as 0 in all
+	// trials. Rarely sampled stacks may not be sampled in any trial, which
+	// says nothing about the estimator.
+	CheckInconclusive
+)
+
+// CheckMean checks whether want lies within the confidence interval of the
+// mean of the estimates got across trials at the given level, see MeanCI.
+func CheckMean(got []float64, want, level float64) (mean, lo, hi float64, result Check) {
+	// Estimates are truncated to integers, so allow for one unit of rounding
+	// error.
+	mean, lo, hi = MeanCI(got, level)
+	switch {
+	case mean == want || (want >= lo-1 && want <= hi+1):
+		return mean, lo, hi, CheckPassed
+	case mean == 0:
+		return mean, lo, hi, CheckInconclusive
+	}
+	return mean, lo, hi, CheckFailed
+}
+
+// Mean returns the arithmetic mean of xs or NaN if xs is empty.
+func Mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// StdDev returns the sample standard deviation of xs or NaN if xs has fewer
+// than two elements.
+func StdDev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return math.NaN()
+	}
+	mean := Mean(xs)
+	var sum float64
+	for _, x := range xs {
+		sum += (x - mean) * (x - mean)
+	}
+	return math.Sqrt(sum / float64(len(xs)-1))
+}
+
+// MeanCI returns the mean of xs and the bounds of its two-sided confidence
+// interval at the given level, e.g. 0.95, based on Student's t distribution.
+// The bounds are NaN if xs has fewer than two elements.
+func MeanCI(xs []float64, level float64) (mean, lo, hi float64) {
+	mean = Mean(xs)
+	if len(xs) < 2 {
+		return mean, math.NaN(), math.NaN()
+	}
+	d := tQuantile((1+level)/2, float64(len(xs)-1)) * StdDev(xs) / math.Sqrt(float64(len(xs)))
+	return mean, mean - d, mean + d
+}
+
+// tQuantile approximates the p quantile of Student's t distribution with df
+// degrees of freedom using the Cornish-Fisher expansion around the normal
+// quantile, which is accurate to a few digits for df >= 3.
+func tQuantile(p, df float64) float64 {
+	z := math.Sqrt2 * math.Erfinv(2*p-1)
+	z3, z5, z7, z9 := math.Pow(z, 3), math.Pow(z, 5), math.Pow(z, 7), math.Pow(z, 9)
+	return z +
+		(z3+z)/(4*df) +
+		(5*z5+16*z3+3*z)/(96*df*df) +
+		(3*z7+19*z5+17*z3-15*z)/(384*df*df*df) +
+		(79*z9+776*z7+1482*z5-1920*z3-945*z)/(92160*df*df*df*df)
+}
+
+// Spearman returns the Spearman rank correlation coefficient of xs and ys,
+// which must have the same length. Ties are assigned their average rank. NaN
+// is returned if either input is constant or has fewer than two elements.
+func Spearman(xs, ys []float64) float64 {
+	return pearson(ranks(xs), ranks(ys))
+}
+
+func ranks(xs []float64) []float64 {
+	idx := make([]int, len(xs))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return xs[idx[a]] < xs[idx[b]] })
+	r := make([]float64, len(xs))
+	for i := 0; i < len(idx); {
+		j := i + 1
+		for j < len(idx) && xs[idx[j]] == xs[idx[i]] {
+			j++
+		}
+		avg := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			r[idx[k]] = avg
+		}
+		i = j
+	}
+	return r
+}
+
+func pearson(xs, ys []float64) float64 {
+	if len(xs) < 2 {
+		return math.NaN()
+	}
+	mx, my := Mean(xs), Mean(ys)
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
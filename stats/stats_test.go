@@ -0,0 +1,130 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+// near reports whether got equals want within tol, treating NaNs as equal.
+func near(got, want, tol float64) bool {
+	if math.IsNaN(want) {
+		return math.IsNaN(got)
+	}
+	return math.Abs(got-want) <= tol
+}
+
+func TestMAPE(t *testing.T) {
+	for _, tt := range []struct {
+		got, want []float64
+		mape      float64
+	}{
+		{[]float64{110, 90}, []float64{100, 100}, 10},
+		{[]float64{150, 100, 50}, []float64{100, 100, 100}, 100.0 / 3},
+		{[]float64{110, 5}, []float64{100, 0}, 10},
+		{[]float64{0}, []float64{100}, 100},
+		{[]float64{5}, []float64{0}, math.NaN()},
+		{nil, nil, math.NaN()},
+	} {
+		if got := MAPE(tt.got, tt.want); !near(got, tt.mape, 1e-9) {
+			t.Errorf("MAPE(%v, %v) = %v, want %v", tt.got, tt.want, got, tt.mape)
+		}
+	}
+}
+
+func TestSpearman(t *testing.T) {
+	for _, tt := range []struct {
+		xs, ys []float64
+		rho    float64
+	}{
+		{[]float64{1, 2, 3, 4}, []float64{10, 20, 30, 40}, 1},
+		{[]float64{1, 2, 3, 4}, []float64{40, 30, 20, 10}, -1},
+		// Monotonic, but not linear.
+		{[]float64{1, 2, 3, 4}, []float64{1, 10, 100, 1000}, 1},
+		{[]float64{1, 2, 3, 4, 5}, []float64{2, 1, 4, 3, 5}, 0.8},
+		// The ties get ranks 2.5 and 2.5.
+		{[]float64{1, 2, 2, 3}, []float64{1, 3, 2, 4}, 4.5 / math.Sqrt(22.5)},
+		{[]float64{1, 1, 2, 2}, []float64{1, 2, 3, 4}, 2 / math.Sqrt(5)},
+		{[]float64{1, 1, 1}, []float64{1, 2, 3}, math.NaN()},
+		{[]float64{1}, []float64{1}, math.NaN()},
+	} {
+		if got := Spearman(tt.xs, tt.ys); !near(got, tt.rho, 1e-9) {
+			t.Errorf("Spearman(%v, %v) = %v, want %v", tt.xs, tt.ys, got, tt.rho)
+		}
+	}
+}
+
+func TestTQuantile(t *testing.T) {
+	// Quantiles from statistical tables. The approximation gets worse for
+	// few degrees of freedom.
+	for _, tt := range []struct {
+		p, df, want, tol float64
+	}{
+		{0.975, 3, 3.182446, 0.005},
+		{0.975, 4, 2.776445, 1e-3},
+		{0.975, 10, 2.228139, 1e-5},
+		{0.975, 29, 2.045230, 1e-5},
+		{0.95, 10, 1.812461, 1e-5},
+		{0.995, 29, 2.756386, 1e-5},
+		{0.975, 1e9, 1.959964, 1e-6},
+		{0.5, 10, 0, 1e-12},
+	} {
+		if got := tQuantile(tt.p, tt.df); !near(got, tt.want, tt.tol) {
+			t.Errorf("tQuantile(%v, %v) = %v, want %v", tt.p, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestMeanCI(t *testing.T) {
+	for _, tt := range []struct {
+		xs           []float64
+		level        float64
+		mean, lo, hi float64
+		tolerance    float64
+	}{
+		// 2.776 * 1.581 / sqrt(5) = 1.963
+		{xs: []float64{1, 2, 3, 4, 5}, level: 0.95, mean: 3, lo: 1.036757, hi: 4.963243, tolerance: 1e-3},
+		{xs: []float64{4, 4, 4}, level: 0.95, mean: 4, lo: 4, hi: 4},
+		{xs: []float64{7}, level: 0.95, mean: 7, lo: math.NaN(), hi: math.NaN()},
+	} {
+		mean, lo, hi := MeanCI(tt.xs, tt.level)
+		if !near(mean, tt.mean, 1e-9) || !near(lo, tt.lo, tt.tolerance) || !near(hi, tt.hi, tt.tolerance) {
+			t.Errorf("MeanCI(%v, %v) = %v, [%v, %v], want %v, [%v, %v]", tt.xs, tt.level, mean, lo, hi, tt.mean, tt.lo, tt.hi)
+		}
+	}
+}
+
+func TestHistogramError(t *testing.T) {
+	for _, tt := range []struct {
+		got, want []float64
+		err       float64
+	}{
+		{[]float64{2, 2}, []float64{2, 2}, 0},
+		// An object in the wrong bucket counts twice.
+		{[]float64{1, 3}, []float64{2, 2}, 50},
+		{[]float64{4, 4}, []float64{2, 2}, 100},
+		{[]float64{0, 0}, []float64{1, 3}, 100},
+	} {
+		if got := HistogramError(tt.got, tt.want); !near(got, tt.err, 1e-9) {
+			t.Errorf("HistogramError(%v, %v) = %v, want %v", tt.got, tt.want, got, tt.err)
+		}
+	}
+}
+
+func TestHistogramDistance(t *testing.T) {
+	for _, tt := range []struct {
+		got, want []float64
+		dist      float64
+	}{
+		{[]float64{1, 3}, []float64{2, 2}, 25},
+		// Only the shape counts.
+		{[]float64{2, 6}, []float64{1, 3}, 0},
+		{[]float64{1, 0}, []float64{0, 1}, 100},
+		{[]float64{1, 1, 2}, []float64{0, 0, 4}, 50},
+		{[]float64{0, 0}, []float64{1, 1}, math.NaN()},
+		{[]float64{1, 1}, []float64{0, 0}, math.NaN()},
+	} {
+		if got := HistogramDistance(tt.got, tt.want); !near(got, tt.dist, 1e-9) {
+			t.Errorf("HistogramDistance(%v, %v) = %v, want %v", tt.got, tt.want, got, tt.dist)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package workload_test
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+	"github.com/felixge/alloc-prof-sim/workload"
+)
+
+func ExampleNewSequential() {
+	w := workload.NewSequential(16, 128)
+	p := profiler.NewPerfect()
+	w.Work(1e6, p)
+	prof := p.Profile()
+	fmt.Println(w.Name(), prof["small"].Bytes, prof["big"].Bytes)
+	// Output: sequential-16-128 16000000 128000000
+}
+
+func ExampleNewStream() {
+	stream, err := workload.ReadAllocStream(strings.NewReader("16,main;foo\n128,main;bar\n16,main;foo\n"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := workload.NewStream(stream)
+	p := profiler.NewPerfect()
+	// Ops beyond the length of the stream replay it from the start.
+	w.Work(2*int64(len(stream.Events)), p)
+	prof := p.Profile()
+	fmt.Println(prof["main;foo"].Objects, prof["main;bar"].Objects)
+	// Output: 4 2
+}
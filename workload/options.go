@@ -0,0 +1,87 @@
+package workload
+
+import "math/rand"
+
+// Option configures a workload created by one of the New functions.
+type Option func(*Config)
+
+// WithRand sets the random number generator of randomized workloads.
+func WithRand(r *rand.Rand) Option {
+	return func(c *Config) { c.Rand = r }
+}
+
+// WithSeed seeds a new random number generator for randomized workloads.
+func WithSeed(seed int64) Option {
+	return WithRand(rand.New(rand.NewSource(seed)))
+}
+
+// WithProcs sets the number of Ps of workloads running goroutines in
+// parallel.
+func WithProcs(n int) Option {
+	return func(c *Config) { c.Procs = n }
+}
+
+func newConfig(small, big int, opts []Option) Config {
+	c := Config{Small: small, Big: big}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// NewSequential returns a workload that allocates all small objects followed
+// by all big objects.
+func NewSequential(small, big int, opts ...Option) Sequential {
+	c := newConfig(small, big, opts)
+	return Sequential{Small: c.Small, Big: c.Big}
+}
+
+// NewInterleave returns a workload that alternates between small and big
+// allocations. With WithRand or WithSeed each allocation only happens with a
+// probability of 50%.
+func NewInterleave(small, big int, opts ...Option) Interleave {
+	c := newConfig(small, big, opts)
+	return Interleave{Small: c.Small, Big: c.Big, Rand: c.Rand}
+}
+
+// NewChurn returns a workload that allocates a small and a big object per op
+// and frees the big object of the previous op.
+func NewChurn(small, big int, opts ...Option) Churn {
+	c := newConfig(small, big, opts)
+	return Churn{Small: c.Small, Big: c.Big}
+}
+
+// NewStartup returns a workload that allocates a single small object at a
+// startup stack before alternating between small and big allocations.
+func NewStartup(small, big int, opts ...Option) Startup {
+	c := newConfig(small, big, opts)
+	return Startup{Small: c.Small, Big: c.Big}
+}
+
+// NewParallel returns a workload that runs a goroutine per P, allocating small
+// objects on even Ps and big ones on odd Ps. Without WithProcs it runs 4 Ps.
+// Without WithRand or WithSeed the random number generator is seeded with 1.
+func NewParallel(small, big int, opts ...Option) Parallel {
+	c := newConfig(small, big, opts)
+	if c.Procs <= 0 {
+		c.Procs = 4
+	}
+	if c.Rand == nil {
+		c.Rand = rand.New(rand.NewSource(1))
+	}
+	return Parallel{Small: c.Small, Big: c.Big, Procs: c.Procs, Rand: c.Rand}
+}
+
+// NewDeep returns a workload that allocates small objects at stacks of random
+// depths. Without WithRand or WithSeed the random number generator is seeded
+// with 1.
+func NewDeep(small int, opts ...Option) Deep {
+	c := newConfig(small, 0, opts)
+	if c.Rand == nil {
+		c.Rand = rand.New(rand.NewSource(1))
+	}
+	return Deep{Small: c.Small, Rand: c.Rand}
+}
+
+// NewStream returns a workload that replays stream.
+func NewStream(stream *AllocStream) Stream { return Stream{Stream: stream} }
@@ -0,0 +1,59 @@
+package workload
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Factory describes how to create a registered workload. Version must be
+// incremented whenever the workload's behavior changes in order to invalidate
+// cached results.
+type Factory struct {
+	Version     int
+	Params      string
+	Description string
+	New         func(Config) Workload
+}
+
+// Spec is a registered workload.
+type Spec struct {
+	Name string
+	Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []Spec
+)
+
+// Register makes a workload available under name, e.g. for selection on the
+// command line. It panics if name is already registered.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, spec := range registry {
+		if spec.Name == name {
+			panic(fmt.Sprintf("workload: Register called twice for %q", name))
+		}
+	}
+	registry = append(registry, Spec{Name: name, Factory: f})
+}
+
+// Lookup returns the workload registered under name.
+func Lookup(name string) (Spec, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, spec := range registry {
+		if spec.Name == name {
+			return spec, true
+		}
+	}
+	return Spec{}, false
+}
+
+// Specs returns all registered workloads in the order they were registered.
+func Specs() []Spec {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]Spec(nil), registry...)
+}
@@ -0,0 +1,139 @@
+package workload
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+// AllocStream is a recorded sequence of allocations.
+type AllocStream struct {
+	Events []AllocEvent
+	// Digest identifies the content of the stream for caching.
+	Digest string
+}
+
+type AllocEvent struct {
+	Kind  EventKind
+	Size  int
+	Stack profiler.StackTrace
+}
+
+type EventKind int
+
+const (
+	EventMalloc EventKind = iota
+	EventFree
+	EventGC
+)
+
+// ReadAllocStream reads newline delimited "size,stack" events from r. A
+// negative size denotes freeing an object of that size and a line containing
+// only "gc" the end of a garbage collection cycle. Empty lines are ignored.
+func ReadAllocStream(r io.Reader) (*AllocStream, error) {
+	var (
+		stream = &AllocStream{}
+		h      = sha256.New()
+		s      = bufio.NewScanner(io.TeeReader(r, h))
+	)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			continue
+		}
+		if text == "gc" {
+			stream.Events = append(stream.Events, AllocEvent{Kind: EventGC})
+			continue
+		}
+		sizeText, stack, ok := strings.Cut(text, ",")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want size,stack: %q", line, text)
+		}
+		size, err := strconv.Atoi(sizeText)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad size: %q", line, sizeText)
+		}
+		e := AllocEvent{Size: size, Stack: profiler.StackTrace(stack)}
+		if size < 0 {
+			e.Kind, e.Size = EventFree, -size
+		}
+		stream.Events = append(stream.Events, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	} else if len(stream.Events) == 0 {
+		return nil, fmt.Errorf("empty allocation stream")
+	}
+	stream.Digest = hex.EncodeToString(h.Sum(nil))
+	return stream, nil
+}
+
+// Stream replays a recorded allocation stream. Each op replays one
+// event, starting over at the beginning once the stream is exhausted.
+type Stream struct {
+	Stream *AllocStream
+}
+
+func (w Stream) Name() string { return "stdin" }
+
+// Len returns the number of events in the stream, which is the default number
+// of ops for this workload.
+func (w Stream) Len() int64 { return int64(len(w.Stream.Events)) }
+
+func (w Stream) Digest() string { return w.Stream.Digest }
+
+// Frees reports whether the stream contains any frees.
+func (w Stream) Frees() bool {
+	for _, e := range w.Stream.Events {
+		if e.Kind == EventFree {
+			return true
+		}
+	}
+	return false
+}
+
+func (w Stream) Work(ops int64, p profiler.Profiler) { w.WorkRange(0, ops, p) }
+
+// WorkRange replays the events of ops start to end, where op i replays event i
+// modulo the length of the stream.
+func (w Stream) WorkRange(start, end int64, p profiler.Profiler) {
+	events := w.Stream.Events
+	// runs[j] is the number of identical allocations starting at event j,
+	// which are handed to the profiler at once.
+	ids := make([]profiler.StackID, len(events))
+	runs := make([]int64, len(events))
+	for j := len(events) - 1; j >= 0; j-- {
+		ids[j] = profiler.Intern(events[j].Stack)
+		runs[j] = 1
+		if j+1 < len(events) && events[j].Kind == EventMalloc && events[j+1] == events[j] {
+			runs[j] += runs[j+1]
+		}
+	}
+	for i, j := start, int(start%int64(len(events))); i < end; j = 0 {
+		for j < len(events) && i < end {
+			switch e := events[j]; e.Kind {
+			case EventMalloc:
+				n := runs[j]
+				if n > end-i {
+					n = end - i
+				}
+				profiler.MallocN(p, e.Size, n, ids[j])
+				i += n
+				j += int(n)
+				continue
+			case EventFree:
+				profiler.FreeID(p, e.Size, ids[j])
+			case EventGC:
+				profiler.GC(p)
+			}
+			i++
+			j++
+		}
+	}
+}
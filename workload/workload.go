@@ -0,0 +1,274 @@
+// Package workload implements simulated programs that allocate memory.
+package workload
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/felixge/alloc-prof-sim/profiler"
+)
+
+// Workload simulates the allocations of a program.
+type Workload interface {
+	Name() string
+	Work(ops int64, p profiler.Profiler)
+}
+
+// Ranger is implemented by workloads that can simulate a part of their ops, so
+// that a long run can be split into parts simulated concurrently.
+// WorkRange(0, ops, p) is the same as Work(ops, p).
+type Ranger interface {
+	Workload
+	WorkRange(start, end int64, p profiler.Profiler)
+}
+
+// Freer is implemented by workloads that may free objects. Sampling profilers
+// only track which of their samples are in use for workloads that do, see
+// profiler.WithInUse.
+type Freer interface {
+	Workload
+	Frees() bool
+}
+
+// Config holds the parameters for creating a workload.
+type Config struct {
+	Small  int
+	Big    int
+	Rand   *rand.Rand
+	Stream *AllocStream
+	// Procs is the number of Ps of workloads running goroutines in
+	// parallel. It defaults to 4.
+	Procs int
+}
+
+func init() {
+	Register("sequential", Factory{
+		Version:     1,
+		Params:      "small, big",
+		Description: "Allocates all small objects followed by all big objects.",
+		New:         func(c Config) Workload { return NewSequential(c.Small, c.Big) },
+	})
+	Register("interleave", Factory{
+		Version:     1,
+		Params:      "small, big",
+		Description: "Alternates between small and big allocations.",
+		New:         func(c Config) Workload { return NewInterleave(c.Small, c.Big) },
+	})
+	Register("interleave-rand", Factory{
+		Version:     1,
+		Params:      "small, big, seed",
+		Description: "Allocates a small and a big object with a probability of 50% each per op.",
+		New:         func(c Config) Workload { return NewInterleave(c.Small, c.Big, WithRand(c.Rand)) },
+	})
+	Register("churn", Factory{
+		Version:     1,
+		Params:      "small, big",
+		Description: "Allocates a small and a big object per op and frees the big object of the previous op.",
+		New:         func(c Config) Workload { return NewChurn(c.Small, c.Big) },
+	})
+	Register("parallel", Factory{
+		Version:     1,
+		Params:      "small, big, procs, seed",
+		Description: "Runs a goroutine per P, allocating small objects on even Ps and big ones on odd Ps, and picks a random one to allocate per op.",
+		New:         func(c Config) Workload { return NewParallel(c.Small, c.Big, WithProcs(c.Procs), WithRand(c.Rand)) },
+	})
+	Register("deep", Factory{
+		Version:     1,
+		Params:      "small, seed",
+		Description: "Allocates a small object per op at a stack of a random depth of up to 64 frames, whose outermost frames identify the stack.",
+		New:         func(c Config) Workload { return NewDeep(c.Small, WithRand(c.Rand)) },
+	})
+	Register("startup", Factory{
+		Version:     1,
+		Params:      "small, big",
+		Description: "Allocates a single small object at a startup stack before alternating between small and big allocations, exposing the bias of always sampling the first allocation in short runs.",
+		New:         func(c Config) Workload { return NewStartup(c.Small, c.Big) },
+	})
+	Register("stdin", Factory{
+		Version:     1,
+		Description: "Replays size,stack lines read from stdin. Runs once through the stream by default.",
+		New:         func(c Config) Workload { return NewStream(c.Stream) },
+	})
+}
+
+var (
+	smallID   = profiler.Intern("small")
+	bigID     = profiler.Intern("big")
+	startupID = profiler.Intern("startup")
+)
+
+// Interleave alternates between allocating Small and Big objects. If Rand
+// is set, each allocation only happens with a probability of 50%.
+type Interleave struct {
+	Small int
+	Big   int
+	Rand  *rand.Rand
+}
+
+func (w Interleave) Name() string {
+	rand := ""
+	if w.Rand != nil {
+		rand = "-rand"
+	}
+	return fmt.Sprintf("interleave%s-%d-%d", rand, w.Small, w.Big)
+}
+
+func (w Interleave) Work(ops int64, p profiler.Profiler) {
+	ip := profiler.AsIDProfiler(p)
+	for i := int64(0); i < ops; i++ {
+		if w.Rand == nil || w.Rand.Float64() < 0.5 {
+			ip.MallocID(w.Small, smallID)
+		}
+		if w.Rand == nil || w.Rand.Float64() < 0.5 {
+			ip.MallocID(w.Big, bigID)
+		}
+	}
+}
+
+// WorkRange simulates end-start ops, as all ops are the same.
+func (w Interleave) WorkRange(start, end int64, p profiler.Profiler) { w.Work(end-start, p) }
+
+// Sequential allocates ops Small objects followed by ops Big objects.
+type Sequential struct {
+	Small int
+	Big   int
+}
+
+func (w Sequential) Name() string {
+	return fmt.Sprintf("sequential-%d-%d", w.Small, w.Big)
+}
+
+func (w Sequential) Work(ops int64, p profiler.Profiler) {
+	profiler.MallocN(p, w.Small, ops, smallID)
+	profiler.MallocN(p, w.Big, ops, bigID)
+}
+
+// WorkRange allocates end-start small objects followed by as many big
+// objects, so the parts of a run allocate the same objects as the whole run.
+func (w Sequential) WorkRange(start, end int64, p profiler.Profiler) { w.Work(end-start, p) }
+
+// Churn allocates a Small and a Big object per op and frees the Big object of
+// the previous op, so all small objects but only the last big object remain
+// in use. It doesn't implement Ranger, as the first op of a part would have to
+// free the last big object of the previous part.
+type Churn struct {
+	Small int
+	Big   int
+}
+
+func (w Churn) Name() string {
+	return fmt.Sprintf("churn-%d-%d", w.Small, w.Big)
+}
+
+func (w Churn) Frees() bool { return true }
+
+func (w Churn) Work(ops int64, p profiler.Profiler) {
+	ip := profiler.AsIDProfiler(p)
+	for i := int64(0); i < ops; i++ {
+		ip.MallocID(w.Small, smallID)
+		if i > 0 {
+			profiler.FreeID(p, w.Big, bigID)
+		}
+		ip.MallocID(w.Big, bigID)
+	}
+}
+
+// Parallel runs a goroutine on each of Procs Ps. The goroutines on even Ps
+// allocate Small objects and those on odd Ps Big ones. Each op the scheduler
+// picks a random P whose goroutine allocates once, so a profiler keeping its
+// state per P, see profiler.PerP, sees the sizes separately, while one with a
+// global state sees them interleaved at random.
+type Parallel struct {
+	Small int
+	Big   int
+	Procs int
+	Rand  *rand.Rand
+}
+
+func (w Parallel) Name() string {
+	return fmt.Sprintf("parallel-%d-%d-%d", w.Procs, w.Small, w.Big)
+}
+
+func (w Parallel) Work(ops int64, p profiler.Profiler) {
+	ip := profiler.AsIDProfiler(p)
+	pp, perP := p.(profiler.ProcProfiler)
+	for i := int64(0); i < ops; i++ {
+		proc := w.Rand.Intn(w.Procs)
+		if perP {
+			pp.SetProc(proc)
+		}
+		if proc%2 == 0 {
+			ip.MallocID(w.Small, smallID)
+		} else {
+			ip.MallocID(w.Big, bigID)
+		}
+	}
+}
+
+// WorkRange simulates end-start ops, as all ops are alike.
+func (w Parallel) WorkRange(start, end int64, p profiler.Profiler) { w.Work(end-start, p) }
+
+// DeepMaxDepth is the maximum depth of the stacks of Deep.
+const DeepMaxDepth = 64
+
+// deepIDs holds the stacks of Deep by depth. The stack of depth d consists of
+// main, handler<d> and d-2 recursive calls of walk, so stacks only differ in
+// their outer frames.
+var deepIDs = func() []profiler.StackID {
+	ids := make([]profiler.StackID, DeepMaxDepth+1)
+	for d := 2; d <= DeepMaxDepth; d++ {
+		frames := []string{"main", fmt.Sprintf("handler%d", d)}
+		for len(frames) < d {
+			frames = append(frames, "walk")
+		}
+		ids[d] = profiler.Intern(profiler.NewStackTrace(frames...))
+	}
+	return ids
+}()
+
+// Deep allocates a Small object per op at a stack of a random depth between 2
+// and DeepMaxDepth. Profilers recording fewer frames merge the deeper stacks,
+// see profiler.TruncateStacks.
+type Deep struct {
+	Small int
+	Rand  *rand.Rand
+}
+
+func (w Deep) Name() string { return fmt.Sprintf("deep-%d", w.Small) }
+
+func (w Deep) Work(ops int64, p profiler.Profiler) {
+	ip := profiler.AsIDProfiler(p)
+	for i := int64(0); i < ops; i++ {
+		ip.MallocID(w.Small, deepIDs[2+w.Rand.Intn(DeepMaxDepth-1)])
+	}
+}
+
+// WorkRange simulates end-start ops, as all ops are alike.
+func (w Deep) WorkRange(start, end int64, p profiler.Profiler) { w.Work(end-start, p) }
+
+// Startup allocates a single Small object at the stack "startup", like an
+// init function, and then alternates between Small and Big objects like
+// Interleave. A sampler whose first sample is certain, see profiler.WithInit,
+// always samples the startup object and scales it up as if it stood for many,
+// which dominates its estimate of the startup stack and, in short runs, of
+// the total.
+type Startup struct {
+	Small int
+	Big   int
+}
+
+func (w Startup) Name() string { return fmt.Sprintf("startup-%d-%d", w.Small, w.Big) }
+
+func (w Startup) Work(ops int64, p profiler.Profiler) { w.WorkRange(0, ops, p) }
+
+// WorkRange allocates the startup object only in the part starting at op 0.
+func (w Startup) WorkRange(start, end int64, p profiler.Profiler) {
+	ip := profiler.AsIDProfiler(p)
+	if start == 0 && end > 0 {
+		ip.MallocID(w.Small, startupID)
+	}
+	for i := start; i < end; i++ {
+		ip.MallocID(w.Small, smallID)
+		ip.MallocID(w.Big, bigID)
+	}
+}